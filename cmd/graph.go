@@ -0,0 +1,164 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sapcc/helm-outdated-dependencies/pkg/helm"
+	"github.com/spf13/cobra"
+	helm_env "k8s.io/helm/pkg/helm/environment"
+)
+
+var graphLongUsage = `
+Exports the dependency graph of Helm charts. Outdated dependencies are highlighted and labelled with the current and latest version.
+
+Examples:
+  $ helm outdated-dependencies graph <chartPath> | dot -Tsvg > dependencies.svg
+  $ helm outdated-dependencies graph <pathToCharts> --recursive --output mermaid
+  $ helm outdated-dependencies graph <chartPath> --recursive-dependencies --output json
+`
+
+// graphFormat is one of graphFormats.
+type graphFormat string
+
+// graphFormats enumerates the available output formats of the graph command.
+var graphFormats = struct {
+	DOT,
+	Mermaid,
+	JSON graphFormat
+}{
+	"dot",
+	"mermaid",
+	"json",
+}
+
+func parseGraphFormat(format string) (graphFormat, error) {
+	switch f := graphFormat(strings.ToLower(format)); f {
+	case graphFormats.DOT, graphFormats.Mermaid, graphFormats.JSON:
+		return f, nil
+	}
+	return "", errors.Errorf("unknown graph format %s", format)
+}
+
+type graphCmd struct {
+	chartPath               string
+	helmSettings            *helm_env.EnvSettings
+	isRecursive             bool
+	isRecursiveDependencies bool
+	outputFormat            graphFormat
+
+	dependencyFilter *helm.Filter
+	repoUpdateOpts   *helm.RepoUpdateOptions
+}
+
+func newGraphCmd() *cobra.Command {
+	g := &graphCmd{
+		helmSettings: &helm_env.EnvSettings{
+			Home: helm.GetHelmHome(),
+		},
+		dependencyFilter: &helm.Filter{},
+	}
+
+	cmd := &cobra.Command{
+		Use:          "graph",
+		Long:         graphLongUsage,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := "."
+			if len(args) > 0 {
+				path = args[0]
+			}
+
+			path, err := filepath.Abs(path)
+			if err != nil {
+				return err
+			}
+			g.chartPath = path
+
+			if repositories, err := cmd.Flags().GetStringSlice("repositories"); err == nil {
+				g.dependencyFilter.Repositories = repositories
+			}
+
+			if deps, err := cmd.Flags().GetStringSlice("dependencies"); err == nil {
+				g.dependencyFilter.DependencyNames = deps
+			}
+
+			if isRecursive, err := cmd.Flags().GetBool("recursive"); err == nil {
+				g.isRecursive = isRecursive
+			}
+
+			if g.repoUpdateOpts, err = repoUpdateOptions(cmd); err != nil {
+				return err
+			}
+
+			if output, err := cmd.Flags().GetString("output"); err == nil {
+				if g.outputFormat, err = parseGraphFormat(output); err != nil {
+					return err
+				}
+			}
+
+			return g.graph()
+		},
+	}
+
+	addCommonFlags(cmd)
+	cmd.Flags().StringP("output", "o", string(graphFormats.DOT), "Output format. One of: dot, mermaid, json.")
+	cmd.Flags().BoolVarP(&g.isRecursiveDependencies, "recursive-dependencies", "", false, "Also include the dependencies of the subcharts in the charts/ folder at every level.")
+
+	return cmd
+}
+
+func (g *graphCmd) graph() error {
+	chartPaths, err := findChartPaths(g.chartPath, g.isRecursive)
+	if err != nil {
+		return err
+	}
+
+	listDependencies := helm.ListDependenciesOfCharts
+	if g.isRecursiveDependencies {
+		listDependencies = helm.ListTransitiveDependenciesOfCharts
+	}
+
+	deps, lookupErr := listDependencies(chartPaths, g.helmSettings, g.dependencyFilter, g.repoUpdateOpts)
+	if lookupErr != nil && !helm.IsLookupError(lookupErr) {
+		return lookupErr
+	}
+
+	graph := helm.NewGraph(g.chartPath, deps)
+	out := graph.DOT()
+	switch g.outputFormat {
+	case graphFormats.Mermaid:
+		out = graph.Mermaid()
+	case graphFormats.JSON:
+		if out, err = formatJSON(graph); err != nil {
+			return err
+		}
+	}
+	fmt.Println(out)
+
+	if lookupErr != nil {
+		return &ExitError{Code: ExitCodes.LookupErrors, Err: lookupErr}
+	}
+	return nil
+}
@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"github.com/pkg/errors"
 	"path/filepath"
+	"strings"
 
 	"github.com/gosuri/uitable"
 	"github.com/sapcc/helm-outdated-dependencies/pkg/helm"
@@ -36,6 +37,12 @@ Helm plugin to manage outdated dependencies of a Helm chart.
 Examples:
   $ helm outdated-dependencies list
   $ helm outdated-dependencies list <chartPath>
+  $ helm outdated-dependencies list <pathToCharts> --recursive
+  $ helm outdated-dependencies list <chartPath> --recursive-dependencies
+  $ helm outdated-dependencies list <chartPath> --output json
+  $ helm outdated-dependencies list <pathToCharts> --recursive --output junit > report.xml
+  $ helm outdated-dependencies list <pathToCharts> --recursive --sort-by libyear --min-libyear 1
+  $ helm outdated-dependencies list <chartPath> --analyze
 `
 
 type listCmd struct {
@@ -43,8 +50,18 @@ type listCmd struct {
 	chartPath                  string
 	helmSettings               *helm_env.EnvSettings
 	failOnOutdatedDependencies bool
+	failOnDeprecated           bool
+	failOn                     helm.IncType
+	isRecursive                bool
+	isRecursiveDependencies    bool
+	isAnalyze                  bool
+	outputFormat               outputFormat
+	sortBy                     helm.SortKey
+	minLibyear                 float64
+	minVersionsBehind          int
 
 	dependencyFilter *helm.Filter
+	repoUpdateOpts   *helm.RepoUpdateOptions
 }
 
 func newListOutdatedDependenciesCmd() *cobra.Command {
@@ -84,45 +101,211 @@ func newListOutdatedDependenciesCmd() *cobra.Command {
 				l.dependencyFilter.DependencyNames = deps
 			}
 
+			if isRecursive, err := cmd.Flags().GetBool("recursive"); err == nil {
+				l.isRecursive = isRecursive
+			}
+
+			if l.repoUpdateOpts, err = repoUpdateOptions(cmd); err != nil {
+				return err
+			}
+
+			if output, err := cmd.Flags().GetString("output"); err == nil {
+				if l.outputFormat, err = parseOutputFormat(output); err != nil {
+					return err
+				}
+			}
+
+			if sortBy, err := cmd.Flags().GetString("sort-by"); err == nil {
+				if l.sortBy, err = helm.ParseSortKey(sortBy); err != nil {
+					return err
+				}
+			}
+
+			if failOn, err := cmd.Flags().GetString("fail-on"); err == nil && failOn != "" {
+				if l.failOn, err = helm.ParseIncType(failOn); err != nil {
+					return err
+				}
+			}
+
+			// Failing on any outdated dependency is the same as failing on patches.
+			if l.failOnOutdatedDependencies && l.failOn == "" {
+				l.failOn = helm.IncTypes.Patch
+			}
+
 			return l.list()
 		},
 	}
 
 	addCommonFlags(cmd)
+	cmd.Flags().StringP("output", "o", string(outputFormats.Table), "Output format. One of: table, json, yaml, markdown, junit, sarif.")
 	cmd.Flags().BoolVarP(&l.failOnOutdatedDependencies, "fail-on-outdated-dependencies", "", false, "Fail if any dependency is outdated. (exit code 1)")
+	cmd.Flags().BoolVarP(&l.isRecursiveDependencies, "recursive-dependencies", "", false, "Also check the dependencies of the subcharts in the charts/ folder at every level.")
+	cmd.Flags().StringP("fail-on", "", "", "Fail if any dependency has an update of the given type or greater available. One of: major, minor, patch. (exit code 1)")
+	cmd.Flags().BoolVarP(&l.failOnDeprecated, "fail-on-deprecated", "", false, "Fail if any dependency is deprecated. (exit code 1)")
+	cmd.Flags().StringP("sort-by", "", string(helm.SortKeys.Name), "Sort the dependencies of each chart. One of: name, libyear, versions-behind, release-date.")
+	cmd.Flags().Float64VarP(&l.minLibyear, "min-libyear", "", 0, "Only report outdated dependencies at least the given number of years behind their latest version, e.g. 0.5. Deprecated dependencies are always reported.")
+	cmd.Flags().IntVarP(&l.minVersionsBehind, "min-versions-behind", "", 0, "Only report outdated dependencies at least the given number of versions behind their latest version. Deprecated dependencies are always reported.")
+	cmd.Flags().BoolVarP(&l.isAnalyze, "analyze", "", false, "Download the current and latest version of outdated dependencies and diff their default values.")
 
 	return cmd
 }
 
 func (l *listCmd) list() error {
-	outdatedDeps, err := helm.ListOutdatedDependencies(l.chartPath, l.helmSettings, l.dependencyFilter)
+	chartPaths, err := findChartPaths(l.chartPath, l.isRecursive)
 	if err != nil {
 		return err
 	}
 
-	fmt.Println(l.formatResults(outdatedDeps))
+	listDependencies := helm.ListDependenciesOfCharts
+	if l.isRecursiveDependencies {
+		listDependencies = helm.ListTransitiveDependenciesOfCharts
+	}
 
-	if l.failOnOutdatedDependencies && len(outdatedDeps) > 0 {
-		return errors.New("dependencies are outdated")
+	deps, lookupErr := listDependencies(chartPaths, l.helmSettings, l.dependencyFilter, l.repoUpdateOpts)
+	if lookupErr != nil && !helm.IsLookupError(lookupErr) {
+		return lookupErr
+	}
+
+	// Thresholds and sorting apply to the reported dependencies.
+	findings := helm.SortResults(helm.FilterBehind(helm.FilterOutdatedOrDeprecated(deps), l.minLibyear, l.minVersionsBehind), l.sortBy)
+	outdatedDeps := helm.FilterOutdated(findings)
+	deprecatedDeps := helm.FilterDeprecated(findings)
+
+	if l.isAnalyze {
+		if err := helm.AnalyzeValues(outdatedDeps, l.helmSettings); err != nil {
+			return err
+		}
+	}
+
+	out, err := l.format(deps, findings, outdatedDeps, deprecatedDeps)
+	if err != nil {
+		return err
+	}
+	fmt.Println(out)
+
+	// Outdated dependencies above the threshold take precedence over lookup errors.
+	if n := countAtLeast(outdatedDeps, l.failOn); l.failOn != "" && n > 0 {
+		return &ExitError{
+			Code: ExitCodes.Outdated,
+			Err:  errors.Errorf("%d dependencies have a %s update or greater available", n, l.failOn),
+		}
+	}
+
+	if l.failOnDeprecated && len(deprecatedDeps) > 0 {
+		return &ExitError{
+			Code: ExitCodes.Outdated,
+			Err:  errors.Errorf("%d dependencies are deprecated", len(deprecatedDeps)),
+		}
+	}
+
+	if lookupErr != nil {
+		return &ExitError{Code: ExitCodes.LookupErrors, Err: lookupErr}
 	}
 
 	return nil
 }
 
-func (l *listCmd) formatResults(results []*helm.Result) string {
-	if len(results) == 0 {
-		return "All charts up to date."
+// countAtLeast counts the results with an update of the given type or greater or as risky as one.
+func countAtLeast(results []*helm.Result, incType helm.IncType) int {
+	n := 0
+	for _, r := range results {
+		if r.Risk().IsAtLeast(incType) {
+			n++
+		}
 	}
+	return n
+}
+
+// format returns the results in the requested output format.
+// JUnit reports contain all dependencies, other formats only the findings, i.e. the outdated and deprecated ones.
+func (l *listCmd) format(deps, findings, outdatedDeps, deprecatedDeps []*helm.Result) (string, error) {
+	switch l.outputFormat {
+	case outputFormats.JSON:
+		return formatJSON(helm.NewReport(l.chartPath, findings))
+	case outputFormats.YAML:
+		return formatYAML(helm.NewReport(l.chartPath, findings))
+	case outputFormats.Markdown:
+		return formatMarkdown(helm.NewReport(l.chartPath, findings)), nil
+	case outputFormats.JUnit:
+		return formatJUnit(helm.NewJUnitReport(l.chartPath, deps))
+	case outputFormats.SARIF:
+		return formatJSON(helm.NewSARIFReport(l.chartPath, findings))
+	}
+
+	if len(outdatedDeps) == 0 && len(deprecatedDeps) == 0 {
+		return "All charts up to date.", nil
+	}
+
+	var tables []string
+	if len(outdatedDeps) > 0 {
+		tables = append(tables, l.formatResults(outdatedDeps))
+	}
+	if len(deprecatedDeps) > 0 {
+		tables = append(tables, l.formatDeprecated(deprecatedDeps))
+	}
+	if l.isAnalyze && len(outdatedDeps) > 0 {
+		tables = append(tables, l.formatValuesAnalysis(outdatedDeps))
+	}
+	return strings.Join(tables, "\n\n"), nil
+}
+
+func (l *listCmd) formatResults(results []*helm.Result) string {
 	table := uitable.New()
 	table.MaxColWidth = l.maxColumnWidth
 	table.AddRow("The following dependencies are outdated:")
-	table.AddRow("ALIAS", "VERSION", "LATEST_VERSION", "REPOSITORY")
-	for _, r := range results {
-		name := r.Alias
-		if name == "" {
-			name = r.Name
+	chartPaths, resultsByChart := helm.GroupResultsByChart(results)
+	for _, chartPath := range chartPaths {
+		if l.isRecursive {
+			table.AddRow("")
+			table.AddRow("CHART:", relativeChartPath(l.chartPath, chartPath))
+		}
+		table.AddRow("ALIAS", "VERSION", "LATEST_VERSION", "APP_VERSION", "BEHIND", "LIBYEAR", "REPOSITORY")
+		for _, r := range resultsByChart[chartPath] {
+			table.AddRow(formatName(r), formatVersion(r), formatLatestVersion(r), formatAppVersion(r), r.VersionsBehind, formatLibyear(r), formatRepository(r.Repository, r.RepositoryName, r.RepositoryURL))
+		}
+		for _, m := range helm.AggregateByChart(resultsByChart[chartPath]) {
+			table.AddRow("TOTAL", "", "", "", m.VersionsBehind, fmt.Sprintf("%.2f", m.Libyear), "")
+		}
+	}
+	return table.String()
+}
+
+func (l *listCmd) formatDeprecated(results []*helm.Result) string {
+	table := uitable.New()
+	table.MaxColWidth = l.maxColumnWidth
+	table.AddRow("The following dependencies are deprecated:")
+	chartPaths, resultsByChart := helm.GroupResultsByChart(results)
+	for _, chartPath := range chartPaths {
+		if l.isRecursive {
+			table.AddRow("")
+			table.AddRow("CHART:", relativeChartPath(l.chartPath, chartPath))
+		}
+		table.AddRow("ALIAS", "VERSION", "REPOSITORY", "REPLACEMENT")
+		for _, r := range resultsByChart[chartPath] {
+			table.AddRow(formatName(r), formatVersion(r), formatRepository(r.Repository, r.RepositoryName, r.RepositoryURL), formatReplacement(r))
+		}
+	}
+	return table.String()
+}
+
+func (l *listCmd) formatValuesAnalysis(results []*helm.Result) string {
+	table := uitable.New()
+	table.MaxColWidth = l.maxColumnWidth
+	table.AddRow("The default values of the outdated dependencies changed as follows:")
+	chartPaths, resultsByChart := helm.GroupResultsByChart(results)
+	for _, chartPath := range chartPaths {
+		if l.isRecursive {
+			table.AddRow("")
+			table.AddRow("CHART:", relativeChartPath(l.chartPath, chartPath))
+		}
+		table.AddRow("ALIAS", "CHANGES", "VALUES_SET_BY_CHART", "SCHEMA")
+		for _, r := range resultsByChart[chartPath] {
+			if r.ValuesAnalysis == nil {
+				table.AddRow(formatName(r), "unknown", "-", "-")
+				continue
+			}
+			table.AddRow(formatName(r), r.ValuesAnalysis.Summary(), formatAffectedValues(r.ValuesAnalysis), formatSchemaAnalysis(r.ValuesAnalysis))
 		}
-		table.AddRow(name, r.Version, r.LatestVersion, r.Repository)
 	}
 	return table.String()
 }
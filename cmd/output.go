@@ -0,0 +1,183 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package cmd
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sapcc/helm-outdated-dependencies/pkg/helm"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// outputFormat is one of outputFormats.
+type outputFormat string
+
+// outputFormats enumerates the available output formats of the list command.
+var outputFormats = struct {
+	Table,
+	JSON,
+	YAML,
+	Markdown,
+	JUnit,
+	SARIF outputFormat
+}{
+	"table",
+	"json",
+	"yaml",
+	"markdown",
+	"junit",
+	"sarif",
+}
+
+func parseOutputFormat(format string) (outputFormat, error) {
+	switch f := outputFormat(strings.ToLower(format)); f {
+	case outputFormats.Table, outputFormats.JSON, outputFormats.YAML, outputFormats.Markdown, outputFormats.JUnit, outputFormats.SARIF:
+		return f, nil
+	}
+	return "", errors.Errorf("unknown output format %s", format)
+}
+
+func formatJSON(report interface{}) (string, error) {
+	data, err := json.MarshalIndent(report, "", "  ")
+	return string(data), err
+}
+
+func formatJUnit(report *helm.JUnitTestSuites) (string, error) {
+	data, err := xml.MarshalIndent(report, "", "  ")
+	return xml.Header + string(data), err
+}
+
+func formatYAML(report *helm.Report) (string, error) {
+	data, err := yamlv3.Marshal(report)
+	return strings.TrimSuffix(string(data), "\n"), err
+}
+
+func formatMarkdown(report *helm.Report) string {
+	if len(report.Dependencies) == 0 {
+		return "All charts up to date."
+	}
+
+	var b strings.Builder
+	b.WriteString("| Chart | Dependency | Version | Latest version | App version | Update type | Behind | Repository |\n")
+	b.WriteString("|---|---|---|---|---|---|---|---|\n")
+	for _, d := range report.Dependencies {
+		name := d.Name
+		if d.Alias != "" {
+			name = fmt.Sprintf("%s (%s)", d.Alias, d.Name)
+		}
+		if d.Path != "" {
+			name = d.Path
+		}
+
+		// Append the resolved version of constraints.
+		version := d.Version
+		if strings.TrimPrefix(version, "v") != d.CurrentVersion {
+			version = fmt.Sprintf("%s (%s)", d.Version, d.CurrentVersion)
+		}
+
+		latestVersion := d.LatestVersion
+		if d.StaleIndex {
+			latestVersion += " (stale index)"
+		}
+		if d.Deprecated {
+			latestVersion += " (deprecated)"
+		}
+		if r := d.Replacement; r != nil {
+			replacement := r.Name
+			if r.LatestVersion != "" {
+				replacement += "@" + r.LatestVersion
+			}
+			latestVersion += fmt.Sprintf(", replace with %s from %s", replacement, r.Repository)
+		}
+
+		// Show the change of the appVersion if any.
+		appVersion := d.LatestAppVersion
+		if d.CurrentAppVersion != "" && d.CurrentAppVersion != d.LatestAppVersion {
+			appVersion = fmt.Sprintf("%s -> %s", d.CurrentAppVersion, d.LatestAppVersion)
+		}
+		if appVersion == "" {
+			appVersion = "-"
+		}
+
+		// Show the versions and, if known, the libyears behind.
+		behind := fmt.Sprintf("%d", d.VersionsBehind)
+		if d.LatestReleaseDate != "" {
+			behind += fmt.Sprintf(" (%.2f libyears)", d.Libyear)
+		}
+
+		// Show the risk if the update is treated like a greater one.
+		updateType := string(d.UpdateType)
+		if d.Risk != "" {
+			updateType += fmt.Sprintf(" (%s risk)", d.Risk)
+		}
+
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s | %s | %s | %s |\n",
+			escapeMarkdown(d.ChartPath), escapeMarkdown(name), escapeMarkdown(version), escapeMarkdown(latestVersion), escapeMarkdown(appVersion), updateType, behind, escapeMarkdown(formatRepository(d.Repository, d.RepositoryName, d.RepositoryURL)),
+		)
+	}
+
+	// List the changes of the default values if analyzed.
+	var values []string
+	for _, d := range report.Dependencies {
+		if d.Values == nil {
+			continue
+		}
+		name := d.Name
+		if d.Alias != "" {
+			name = d.Alias
+		}
+		if d.Path != "" {
+			name = d.Path
+		}
+		values = append(values, formatValuesChanges(fmt.Sprintf("%s (chart %s)", name, d.ChartPath), d.Values))
+	}
+	if len(values) > 0 {
+		b.WriteString("\nChanges of the default values:\n\n")
+		b.WriteString(strings.Join(values, ""))
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// formatValuesChanges returns the summary of the changes of the default values of a dependency as Markdown list item.
+// The removed or renamed values set by the chart and the breaking changes of the values schema are listed below.
+func formatValuesChanges(name string, values *helm.ReportValues) string {
+	var b strings.Builder
+	a := &helm.ValuesAnalysis{Changes: values.Changes}
+	fmt.Fprintf(&b, "- %s: %s\n", name, a.Summary())
+	for _, c := range values.Affected {
+		fmt.Fprintf(&b, "  - **set by the chart**: %s\n", c)
+	}
+	for _, c := range values.SchemaChanges {
+		fmt.Fprintf(&b, "  - **schema**: %s\n", c)
+	}
+	for _, e := range values.SchemaErrors {
+		fmt.Fprintf(&b, "  - **invalid values**: %s\n", e)
+	}
+	return b.String()
+}
+
+// escapeMarkdown escapes characters, which would break a Markdown table.
+func escapeMarkdown(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
@@ -20,6 +20,12 @@
 package cmd
 
 import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sapcc/helm-outdated-dependencies/pkg/helm"
 	"github.com/spf13/cobra"
 )
 
@@ -29,21 +35,62 @@ Helm plugin to manage outdated dependencies of a Helm chart.
 Examples:
   $ helm outdated-dependencies list <pathToChart> 										- Checks if there's a newer version of any dependency available in the specified repository.
   $ helm outdated-dependencies list <pathToChart> --repositories repo1.corp,repo2.corp 	- Checks if there's a newer version of any dependency available only using the given repositories. 
+  $ helm outdated-dependencies list <pathToCharts> --recursive 							- Checks the dependencies of every chart found in the given directory tree.
 
   $ helm outdated-dependencies update <pathToChart> 							- Updates all outdated dependencies to the latest version found in the repository.
   $ helm outdated-dependencies update <pathToChart> --increment-chart-version	- Updates all outdated dependencies to the latest version found in the repository and increments the version of the Helm chart.
+
+  $ helm outdated-dependencies graph <pathToChart> | dot -Tsvg > deps.svg		- Exports the dependency graph with outdated dependencies highlighted as DOT, Mermaid or JSON.
 `
 
+// ExitCodes enumerates the exit codes of the plugin.
+// Errors, which are no ExitError, exit with Error (3) instead of 1 as in previous versions.
+var ExitCodes = struct {
+	Success,
+	Outdated,
+	LookupErrors,
+	Error int
+}{
+	0,
+	1,
+	2,
+	3,
+}
+
+// ExitError is an error, which terminates the plugin with the given exit code.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+// ExitCode returns the exit code for the given error.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitCodes.Success
+	}
+	if e, ok := err.(*ExitError); ok {
+		return e.Code
+	}
+	return ExitCodes.Error
+}
+
 func New() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:       "outdated-dependencies",
 		Long:      rootCmdLongUsage,
 		ValidArgs: []string{"chartPath"},
+		// Errors are written to stderr by main, so they are neither printed twice nor mixed into reports on stdout.
+		SilenceErrors: true,
 	}
 
 	cmd.AddCommand(
 		newListOutdatedDependenciesCmd(),
 		newUpdateOutdatedDependenciesCmd(),
+		newGraphCmd(),
 	)
 
 	return cmd
@@ -53,4 +100,151 @@ func addCommonFlags(cmd *cobra.Command) {
 	cmd.Flags().IntP("max-column-width", "w", 60, "Max column width to use for tables")
 	cmd.Flags().StringSliceP("repositories", "r", []string{}, "Limit search to the given repository URLs. Can also just provide a part of the URL.")
 	cmd.Flags().StringSliceP("dependencies", "", []string{}, "Only considers the given dependencies.")
+	cmd.Flags().BoolP("recursive", "", false, "Walk the given path and consider every chart found in the directory tree.")
+
+	defaultRepoOpts := helm.DefaultRepoUpdateOptions()
+	cmd.Flags().IntP("concurrency", "", defaultRepoOpts.Concurrency, "Maximum number of repository indices downloaded at the same time.")
+	cmd.Flags().DurationP("timeout", "", defaultRepoOpts.Timeout, "Timeout for downloading a repository index. 0 disables the timeout.")
+	cmd.Flags().IntP("retries", "", defaultRepoOpts.Retries, "Number of retries if downloading a repository index fails because of a timeout, network or server error.")
+	cmd.Flags().BoolP("offline", "", false, "Do not download the repository indices. Only use the cached ones.")
+	cmd.Flags().DurationP("cache-ttl", "", 0, "Do not download a repository index if the cached one is younger, e.g. 1h. 0 always downloads.")
+	cmd.Flags().DurationP("max-cache-age", "", defaultRepoOpts.MaxCacheAge, "Warn if a used cached repository index is older. 0 disables the warning.")
+}
+
+// repoUpdateOptions returns the options for downloading the repository indices given by the common flags.
+func repoUpdateOptions(cmd *cobra.Command) (*helm.RepoUpdateOptions, error) {
+	opts := helm.DefaultRepoUpdateOptions()
+	if concurrency, err := cmd.Flags().GetInt("concurrency"); err == nil {
+		if concurrency < 1 {
+			return nil, errors.Errorf("invalid concurrency %d, must be at least 1", concurrency)
+		}
+		opts.Concurrency = concurrency
+	}
+	if timeout, err := cmd.Flags().GetDuration("timeout"); err == nil {
+		opts.Timeout = timeout
+	}
+	if retries, err := cmd.Flags().GetInt("retries"); err == nil {
+		if retries < 0 {
+			return nil, errors.Errorf("invalid number of retries %d, must not be negative", retries)
+		}
+		opts.Retries = retries
+	}
+	if offline, err := cmd.Flags().GetBool("offline"); err == nil {
+		opts.Offline = offline
+	}
+	if cacheTTL, err := cmd.Flags().GetDuration("cache-ttl"); err == nil {
+		opts.CacheTTL = cacheTTL
+	}
+	if maxCacheAge, err := cmd.Flags().GetDuration("max-cache-age"); err == nil {
+		opts.MaxCacheAge = maxCacheAge
+	}
+	return opts, nil
+}
+
+// findChartPaths returns the given chart path or, if recursive, the paths of all charts found below it.
+func findChartPaths(path string, isRecursive bool) ([]string, error) {
+	if !isRecursive {
+		return []string{path}, nil
+	}
+	return helm.FindCharts(path)
+}
+
+// relativeChartPath returns the path of the chart relative to the given root path if possible.
+func relativeChartPath(rootPath, chartPath string) string {
+	relPath, err := filepath.Rel(rootPath, chartPath)
+	if err != nil {
+		return chartPath
+	}
+	return relPath
+}
+
+// formatVersion returns the version of the dependency. For constraints the newest version satisfying it is appended.
+func formatVersion(r *helm.Result) string {
+	if r.Constraint == nil {
+		return r.Version
+	}
+	return fmt.Sprintf("%s (%s)", r.Version, r.CurrentVersion.String())
+}
+
+// formatLatestVersion returns the latest version of the dependency. It is marked if the cached index was used.
+func formatLatestVersion(r *helm.Result) string {
+	if r.IndexError == nil {
+		return r.LatestVersion.String()
+	}
+	return fmt.Sprintf("%s (stale index)", r.LatestVersion.String())
+}
+
+// formatRepository returns the repository of the dependency. For repositories configured in Helm the name is prepended to the URL.
+func formatRepository(repository, repositoryName, repositoryURL string) string {
+	if repositoryName == "" {
+		return repository
+	}
+	return fmt.Sprintf("%s (%s)", repositoryName, repositoryURL)
+}
+
+// formatName returns the alias of the dependency or, if not set, its name. Transitive dependencies are shown with their path.
+func formatName(r *helm.Result) string {
+	if len(r.Path) > 0 {
+		return r.PathString()
+	}
+	if r.Alias != "" {
+		return r.Alias
+	}
+	return r.Name
+}
+
+// formatReplacement returns the suggested replacement of a deprecated dependency or "-" if there is none.
+func formatReplacement(r *helm.Result) string {
+	if r.Replacement == nil {
+		return "-"
+	}
+	return r.Replacement.String()
+}
+
+// formatAppVersion returns the appVersion of the dependency like "0.38.1 -> 0.44.0" if it changed or "-" if unknown.
+func formatAppVersion(r *helm.Result) string {
+	switch {
+	case r.IsAppVersionChanged() && r.CurrentAppVersion != "":
+		return fmt.Sprintf("%s -> %s", r.CurrentAppVersion, r.LatestAppVersion)
+	case r.LatestAppVersion != "":
+		return r.LatestAppVersion
+	case r.CurrentAppVersion != "":
+		return r.CurrentAppVersion
+	}
+	return "-"
+}
+
+// formatLibyear returns the libyears of the dependency or "-" if the release dates are unknown.
+func formatLibyear(r *helm.Result) string {
+	if !r.HasReleaseDates() {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f", r.Libyear())
+}
+
+// formatAffectedValues returns the removed or renamed values set by the chart like "image.tag removed" or "-" if there are none.
+func formatAffectedValues(a *helm.ValuesAnalysis) string {
+	if len(a.Affected) == 0 {
+		return "-"
+	}
+	affected := make([]string, len(a.Affected))
+	for i, c := range a.Affected {
+		affected[i] = c.String()
+	}
+	return strings.Join(affected, ", ")
+}
+
+// formatSchemaAnalysis returns the breaking changes of the values schema and the violations by the values set by the chart or "-" if there are none.
+func formatSchemaAnalysis(a *helm.ValuesAnalysis) string {
+	var res []string
+	for _, c := range a.SchemaChanges {
+		res = append(res, c.String())
+	}
+	for _, e := range a.SchemaErrors {
+		res = append(res, "invalid: "+e)
+	}
+	if len(res) == 0 {
+		return "-"
+	}
+	return strings.Join(res, ", ")
 }
@@ -21,6 +21,7 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
 	"time"
@@ -36,18 +37,24 @@ type updateCmd struct {
 	chartPath               string
 	helmSettings            *helm_env.EnvSettings
 	maxColumnWidth          uint
-	indent                  int
 	isIncrementChartVersion bool
+	isRecursive             bool
+	isDryRun                bool
+	isReplaceDeprecated     bool
+	isAnalyze               bool
 	dependencyFilter        *helm.Filter
+	repoUpdateOpts          *helm.RepoUpdateOptions
 	git                     *git.Git
-	hub                     *git.Hub
 
 	// **Experimental**
 	// isAutoUpdate updates the dependencies, increments version of the chart with the dependency and (git) commits the changes.
 	isAutoUpdate,
 	isOnlyPullRequest bool
 	authorName,
-	authorEmail string
+	authorEmail,
+	apiBaseURL,
+	tokenFile string
+	providerType git.ProviderType
 }
 
 var updateLongUsage = `
@@ -59,6 +66,18 @@ Examples:
 
 	# Only update specific dependencies of the given chart.
 	$ helm outdated-dependencies update <chartPath> --dependencies kube-state-metrics,prometheus-operator
+
+	# Update dependencies of every chart found in the given directory tree.
+	$ helm outdated-dependencies update <pathToCharts> --recursive
+
+	# Only show the changes an update would make without touching any file.
+	$ helm outdated-dependencies update <chartPath> --increment-chart-version --dry-run
+
+	# Also replace deprecated dependencies by their configured replacement.
+	$ helm outdated-dependencies update <chartPath> --replace-deprecated
+
+	# Diff the default values of the updated dependencies and add the result to the pull requests.
+	$ helm outdated-dependencies update <chartPath> --auto-update --analyze
 `
 
 func newUpdateOutdatedDependenciesCmd() *cobra.Command {
@@ -87,12 +106,28 @@ func newUpdateOutdatedDependenciesCmd() *cobra.Command {
 				u.dependencyFilter.DependencyNames = deps
 			}
 
+			if isRecursive, err := cmd.Flags().GetBool("recursive"); err == nil {
+				u.isRecursive = isRecursive
+			}
+
+			repoUpdateOpts, err := repoUpdateOptions(cmd)
+			if err != nil {
+				return err
+			}
+			u.repoUpdateOpts = repoUpdateOpts
+
+			if provider, err := cmd.Flags().GetString("provider"); err == nil && provider != "" {
+				if u.providerType, err = git.ParseProviderType(provider); err != nil {
+					return err
+				}
+			}
+
 			path := "."
 			if len(args) > 0 {
 				path = args[0]
 			}
 
-			path, err := filepath.Abs(path)
+			path, err = filepath.Abs(path)
 			if err != nil {
 				return err
 			}
@@ -104,36 +139,85 @@ func newUpdateOutdatedDependenciesCmd() *cobra.Command {
 
 	addCommonFlags(cmd)
 	cmd.Flags().BoolVarP(&u.isIncrementChartVersion, "increment-chart-version", "", false, "Increment the version of the Helm chart if requirements are updated.")
-	cmd.Flags().IntVarP(&u.indent, "indent", "", 4, "Indent to use when writing the requirements.yaml .")
+	cmd.Flags().BoolVarP(&u.isDryRun, "dry-run", "", false, "Print the changes as unified diff instead of writing them.")
+	cmd.Flags().BoolVarP(&u.isReplaceDeprecated, "replace-deprecated", "", false, "Replace deprecated dependencies by their replacement configured in the plugin configuration.")
+	cmd.Flags().BoolVarP(&u.isAnalyze, "analyze", "", false, "Download the current and latest version of the updated dependencies and diff their default values.")
+	cmd.Flags().IntP("indent", "", 4, "Indent to use when writing the requirements.yaml .")
+	cmd.Flags().MarkDeprecated("indent", "the layout of the requirements.yaml is kept as it is")
 
 	// **Experimental** Update dependencies of the given chart, commit and push to upstream using git.
 	cmd.Flags().BoolVar(&u.isAutoUpdate, "auto-update", false, "**Experimental** Update dependencies of the given chart, commit and push to upstream using git.")
 	cmd.Flags().StringVar(&u.authorName, "author-name", "", "The name of the author and committer to be used when auto update is enabled.")
 	cmd.Flags().StringVar(&u.authorEmail, "author-email", "", "The email of the author and committer to be used when auto update is enabled.")
 	cmd.Flags().BoolVar(&u.isOnlyPullRequest, "only-pull-requests", false, "Only use pull requests. Do not commit minor changes to master branch.")
+	cmd.Flags().String("provider", "", "The hosting provider used to open pull requests. One of: github, gitlab, gitea. Detected from the git remote if not given.")
+	cmd.Flags().StringVar(&u.apiBaseURL, "api-base-url", "", "The base URL of the API of the provider, e.g. https://github.corp/api/v3. Derived from the git remote if not given.")
+	cmd.Flags().StringVar(&u.apiBaseURL, "github-base-url", "", "The base URL of the GitHub API.")
+	cmd.Flags().MarkDeprecated("github-base-url", "use --api-base-url instead")
+	cmd.Flags().StringVar(&u.tokenFile, "token-file", "", "The file containing the token used to push and open pull requests. The GITHUB_TOKEN, GITLAB_TOKEN or GITEA_TOKEN environment variable is used if not given.")
 
 	return cmd
 }
 
 func (u *updateCmd) update() error {
-	outdatedDeps, err := helm.ListOutdatedDependencies(u.chartPath, u.helmSettings, u.dependencyFilter)
+	chartPaths, err := findChartPaths(u.chartPath, u.isRecursive)
 	if err != nil {
 		return err
 	}
 
+	// Dependencies, which could not be looked up, are skipped.
+	deps, err := helm.ListDependenciesOfCharts(chartPaths, u.helmSettings, u.dependencyFilter, u.repoUpdateOpts)
+	if err != nil && !helm.IsLookupError(err) {
+		return err
+	}
+	outdatedDeps := helm.FilterUpdates(deps, u.isReplaceDeprecated)
+
+	for _, r := range helm.FilterDeprecated(deps) {
+		if !r.Replace {
+			fmt.Fprintf(os.Stderr, "warning: dependency %s of chart %s is deprecated, replacement: %s\n", formatName(r), relativeChartPath(u.chartPath, r.ChartPath), formatReplacement(r))
+		}
+	}
+
 	if len(outdatedDeps) == 0 {
 		fmt.Println("All charts up-to-date.")
 		return nil
 	}
 	fmt.Println(u.formatResults(outdatedDeps))
 
-	if u.isIncrementChartVersion || u.isAutoUpdate {
-		if err = helm.IncrementChartVersion(u.chartPath, helm.IncTypes.Patch); err != nil {
+	if u.isAnalyze {
+		if err := helm.AnalyzeValues(outdatedDeps, u.helmSettings); err != nil {
+			return err
+		}
+		for _, r := range outdatedDeps {
+			if r.ValuesAnalysis == nil {
+				continue
+			}
+			for _, c := range r.ValuesAnalysis.Affected {
+				fmt.Fprintf(os.Stderr, "warning: the value %s set by chart %s was %s in version %s of dependency %s\n", c.Key, relativeChartPath(u.chartPath, r.ChartPath), strings.TrimPrefix(c.String(), c.Key+" "), r.LatestVersion, formatName(r))
+			}
+			for _, e := range r.ValuesAnalysis.SchemaErrors {
+				fmt.Fprintf(os.Stderr, "warning: the values of chart %s violate the schema of version %s of dependency %s: %s\n", relativeChartPath(u.chartPath, r.ChartPath), r.LatestVersion, formatName(r), e)
+			}
+		}
+	}
+
+	chartPaths, outdatedDepsByChart := helm.GroupResultsByChart(outdatedDeps)
+	for _, chartPath := range chartPaths {
+		if err := u.updateChart(chartPath, outdatedDepsByChart[chartPath]); err != nil {
 			return err
 		}
 	}
 
-	if err := helm.UpdateDependencies(u.chartPath, outdatedDeps, u.indent, u.helmSettings); err != nil {
+	return nil
+}
+
+// updateChart updates the outdated dependencies of a single chart.
+func (u *updateCmd) updateChart(chartPath string, outdatedDeps []*helm.Result) error {
+	if u.isDryRun {
+		return u.printChanges(chartPath, outdatedDeps)
+	}
+
+	if err := helm.UpdateChart(chartPath, outdatedDeps, u.chartIncType(), u.helmSettings, u.repoUpdateOpts); err != nil {
 		return err
 	}
 
@@ -146,17 +230,27 @@ func (u *updateCmd) update() error {
 	maxIncType := helm.IncTypes.Patch
 	depNames := make([]string, len(outdatedDeps))
 	for idx, dep := range outdatedDeps {
-		if i := helm.GetIncType(dep.CurrentVersion, dep.LatestVersion); maxIncType.IsGreater(i) {
-			maxIncType = i
-		}
 		depName := dep.Alias
 		if depName == "" {
 			depName = dep.Name
 		}
-		depNames[idx] = fmt.Sprintf("%s@%s",  depName, dep.LatestVersion)
+
+		// Risky updates like replacing a dependency are treated like a major update.
+		if i := dep.Risk(); maxIncType.IsGreater(i) {
+			maxIncType = i
+		}
+
+		if dep.Replace {
+			depNames[idx] = fmt.Sprintf("%s@%s (replaces %s)", dep.Replacement.Name, dep.Replacement.LatestVersion, depName)
+			continue
+		}
+		depNames[idx] = fmt.Sprintf("%s@%s", depName, dep.LatestVersion)
+		if dep.IsAppVersionChanged() {
+			depNames[idx] += fmt.Sprintf(" (app version %s)", formatAppVersion(dep))
+		}
 	}
 
-	chartName, err := helm.GetChartName(u.chartPath)
+	chartName, err := helm.GetChartName(chartPath)
 	if err != nil {
 		return err
 	}
@@ -165,15 +259,66 @@ func (u *updateCmd) update() error {
 
 	// If potential breaking changes are expected, use a pull request.
 	if u.isOnlyPullRequest || maxIncType == helm.IncTypes.Major || maxIncType == helm.IncTypes.Minor {
-		return u.upstreamMajorChanges(commitMessage, chartName)
+		return u.upstreamMajorChanges(chartPath, commitMessage, chartName, pullRequestBody(commitMessage, outdatedDeps))
 	}
 
-	return u.upstreamMinorChanges(commitMessage)
+	return u.upstreamMinorChanges(chartPath, commitMessage)
 }
 
-// upstreamMinorChanges commits the changes to the master branch of the upstream github repository.
-func (u *updateCmd) upstreamMinorChanges(commitMessage string) error {
-	g, err := git.NewGit(u.chartPath, u.authorName, u.authorEmail)
+// chartIncType returns how the version of an updated chart is incremented.
+func (u *updateCmd) chartIncType() helm.IncType {
+	if u.isIncrementChartVersion || u.isAutoUpdate {
+		return helm.IncTypes.Patch
+	}
+	return helm.IncTypes.None
+}
+
+// printChanges prints the changes an update of the chart would make as unified diff.
+func (u *updateCmd) printChanges(chartPath string, outdatedDeps []*helm.Result) error {
+	changes, err := helm.PlanUpdateDependencies(chartPath, outdatedDeps, u.chartIncType(), u.helmSettings)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range changes {
+		diff, err := c.UnifiedDiff(relativeChartPath(u.chartPath, c.Path))
+		if err != nil {
+			return err
+		}
+		fmt.Print(diff)
+	}
+	return nil
+}
+
+// newGit returns the git integration for the given chart pushing with the token of the provider.
+func (u *updateCmd) newGit(chartPath string) (*git.Git, string, error) {
+	g, err := git.NewGit(chartPath, u.authorName, u.authorEmail)
+	if err != nil {
+		return nil, "", err
+	}
+
+	providerType := u.providerType
+	if providerType == "" {
+		remoteURL, err := g.GetRemoteURL()
+		if err != nil {
+			return nil, "", err
+		}
+		if providerType, err = git.DetectProviderType(remoteURL); err != nil {
+			return nil, "", err
+		}
+	}
+
+	token, err := git.LoadToken(u.tokenFile, providerType)
+	if err != nil {
+		return nil, "", err
+	}
+	g.SetToken(token)
+	return g, token, nil
+}
+
+// upstreamMinorChanges commits the changes to the master branch of the upstream repository.
+func (u *updateCmd) upstreamMinorChanges(chartPath, commitMessage string) error {
+	g, _, err := u.newGit(chartPath)
 	if err != nil {
 		return err
 	}
@@ -195,9 +340,18 @@ func (u *updateCmd) upstreamMinorChanges(commitMessage string) error {
 	return err
 }
 
-// upstreamMajorChanges same as upstreamMinorChanges but via github.com pull request.
-func (u *updateCmd) upstreamMajorChanges(commitMessage, chartName string) error {
-	g, err := git.NewGit(u.chartPath, u.authorName, u.authorEmail)
+// upstreamMajorChanges same as upstreamMinorChanges but via pull request or, on GitLab, merge request.
+func (u *updateCmd) upstreamMajorChanges(chartPath, commitMessage, chartName, body string) error {
+	g, token, err := u.newGit(chartPath)
+	if err != nil {
+		return err
+	}
+
+	remoteURL, err := g.GetRemoteURL()
+	if err != nil {
+		return err
+	}
+	provider, err := git.NewProvider(u.providerType, remoteURL, u.apiBaseURL, token)
 	if err != nil {
 		return err
 	}
@@ -207,7 +361,7 @@ func (u *updateCmd) upstreamMajorChanges(commitMessage, chartName string) error
 	if err != nil {
 		return err
 	}
-	defer g.CheckoutBranch("master")
+	defer g.CheckoutBranch(g.BranchName())
 
 	res, err = g.Diff()
 	if err != nil {
@@ -227,14 +381,26 @@ func (u *updateCmd) upstreamMajorChanges(commitMessage, chartName string) error
 	}
 	fmt.Println(res)
 
-	hub, err := git.NewHub(u.chartPath)
+	pr, err := provider.OpenPullRequest(branchName, g.BranchName(), fmt.Sprintf("[%s] updating dependencies", chartName), body)
 	if err != nil {
 		return err
 	}
+	fmt.Printf("Opened pull request #%d: %s\n", pr.Number, pr.URL)
+	return nil
+}
 
-	res, err = hub.OpenPullRequestToMaster(branchName, fmt.Sprintf("[%s] updating dependencies", chartName), commitMessage)
-	fmt.Println(res)
-	return err
+// pullRequestBody returns the commit message followed by the changes of the default values of the analyzed dependencies.
+func pullRequestBody(commitMessage string, outdatedDeps []*helm.Result) string {
+	var values []string
+	for _, r := range outdatedDeps {
+		if r.ValuesAnalysis != nil {
+			values = append(values, formatValuesChanges(formatName(r), helm.NewReportValues(r.ValuesAnalysis)))
+		}
+	}
+	if len(values) == 0 {
+		return commitMessage
+	}
+	return fmt.Sprintf("%s\n\nChanges of the default values:\n\n%s", commitMessage, strings.TrimSuffix(strings.Join(values, ""), "\n"))
 }
 
 func (u *updateCmd) formatResults(results []*helm.Result) string {
@@ -244,13 +410,20 @@ func (u *updateCmd) formatResults(results []*helm.Result) string {
 	table := uitable.New()
 	table.MaxColWidth = u.maxColumnWidth
 	table.AddRow("Updating the following dependencies to their latest version:")
-	table.AddRow("ALIAS", "VERSION", "LATEST_VERSION", "REPOSITORY")
-	for _, r := range results {
-		name := r.Alias
-		if name == "" {
-			name = r.Name
+	chartPaths, resultsByChart := helm.GroupResultsByChart(results)
+	for _, chartPath := range chartPaths {
+		if u.isRecursive {
+			table.AddRow("")
+			table.AddRow("CHART:", relativeChartPath(u.chartPath, chartPath))
+		}
+		table.AddRow("ALIAS", "VERSION", "LATEST_VERSION", "APP_VERSION", "REPOSITORY")
+		for _, r := range resultsByChart[chartPath] {
+			if r.Replace {
+				table.AddRow(formatName(r), formatVersion(r), formatReplacement(r), "-", formatRepository(r.Repository, r.RepositoryName, r.RepositoryURL))
+				continue
+			}
+			table.AddRow(formatName(r), formatVersion(r), formatLatestVersion(r), formatAppVersion(r), formatRepository(r.Repository, r.RepositoryName, r.RepositoryURL))
 		}
-		table.AddRow(name, r.Version, r.LatestVersion, r.Repository)
 	}
 	return table.String()
 }
@@ -28,7 +28,7 @@ import (
 
 func main() {
 	if err := cmd.New().Execute(); err != nil {
-		fmt.Println(err.Error())
-		os.Exit(1)
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(cmd.ExitCode(err))
 	}
 }
@@ -74,10 +74,8 @@ func (c *Command) Run(args ...string) (string, error) {
 
 // verify checks if the command is installed.
 func (c *Command) verify() error {
-	res, err := c.Run("version")
-	if err != nil && strings.Contains(err.Error(), "not found") || strings.Contains(res, "not found") {
-		return errCmdNotInstalled
+	if _, err := exec.LookPath(c.cmd); err != nil {
+		return errors.Wrap(errCmdNotInstalled, c.cmd)
 	}
-
 	return nil
 }
@@ -21,17 +21,12 @@ package git
 
 import (
 	"fmt"
-	"os"
-	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/sapcc/helm-outdated-dependencies/pkg/cmd"
 )
 
-var (
-	errGitNoRemote = errors.New("git remote has no remote configured")
-	errGithubNoToken = errors.New("GITHUB_TOKEN environment variable no set")
-)
+var errGitNoRemote = errors.New("git remote has no remote configured")
 
 // Git wraps the git command line.
 type Git struct {
@@ -41,6 +36,9 @@ type Git struct {
 	remoteName,
 	authorName,
 	authorEmail string
+
+	// token used to push. The token environment variable of the remote's provider is used if not set.
+	token string
 }
 
 // NewGit returns a new Git or an error.
@@ -79,6 +77,16 @@ func NewGit(path, authorName, authorEmail string) (*Git, error) {
 	return g, nil
 }
 
+// SetToken sets the token used to push.
+func (g *Git) SetToken(token string) {
+	g.token = token
+}
+
+// BranchName returns the name of the branch changes are pushed to.
+func (g *Git) BranchName() string {
+	return g.branchName
+}
+
 // Commit adds and commits all changes.
 func (g *Git) Commit(message string) (string, error) {
 	res, err := g.Run(
@@ -179,14 +187,16 @@ func (g *Git) getPushURL() (string, error) {
 		return "", err
 	}
 
-	ghToken, ok := os.LookupEnv("GITHUB_TOKEN")
-	if !ok {
-		return "", errGithubNoToken
+	token := g.token
+	if token == "" {
+		providerType, err := DetectProviderType(remote)
+		if err != nil {
+			return "", err
+		}
+		if token, err = LoadToken("", providerType); err != nil {
+			return "", err
+		}
 	}
 
-	remote = strings.TrimPrefix(remote, "https://")
-	remote = strings.TrimPrefix(remote, "git@")
-	remote = strings.ReplaceAll(remote, ":", "/")
-
-	return fmt.Sprintf("https://%s:%s@%s", g.authorName, ghToken, remote), nil
+	return pushURL(remote, g.authorName, token)
 }
@@ -0,0 +1,76 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package git
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Gitea opens pull requests using the Gitea REST API.
+type Gitea struct {
+	client *http.Client
+
+	// baseURL of the API like "https://gitea.com/api/v1".
+	baseURL,
+	repoPath,
+	token string
+}
+
+// NewGitea returns a new Gitea client for the repository of the given remote URL.
+// The base URL of the API is derived from the scheme and host of the remote URL if not given.
+func NewGitea(remoteURL, baseURL, token string) (*Gitea, error) {
+	scheme, host, repoPath, err := parseRemoteURL(remoteURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if baseURL == "" {
+		baseURL = fmt.Sprintf("%s://%s/api/v1", scheme, host)
+	}
+
+	return &Gitea{
+		client:   &http.Client{Timeout: 30 * time.Second},
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		repoPath: repoPath,
+		token:    token,
+	}, nil
+}
+
+// OpenPullRequest opens a pull request from the head to the base branch.
+func (g *Gitea) OpenPullRequest(head, base, title, body string) (*PullRequest, error) {
+	var pr struct {
+		Number  int    `json:"number"`
+		HTMLURL string `json:"html_url"`
+	}
+	err := postJSON(g.client, fmt.Sprintf("%s/repos/%s/pulls", g.baseURL, g.repoPath),
+		map[string]string{"Authorization": "token " + g.token},
+		map[string]string{"title": title, "head": head, "base": base, "body": body},
+		&pr,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening pull request")
+	}
+	return &PullRequest{Number: pr.Number, URL: pr.HTMLURL}, nil
+}
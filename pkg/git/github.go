@@ -0,0 +1,86 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package git
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const githubHost = "github.com"
+
+// GitHub opens pull requests using the GitHub REST API. GitHub Enterprise is supported.
+type GitHub struct {
+	client *http.Client
+
+	// baseURL of the API like "https://api.github.com" or "https://github.corp/api/v3".
+	baseURL,
+	repoPath,
+	token string
+}
+
+// NewGitHub returns a new GitHub client for the repository of the given remote URL.
+// The base URL of the API is derived from the scheme and host of the remote URL if not given.
+func NewGitHub(remoteURL, baseURL, token string) (*GitHub, error) {
+	scheme, host, repoPath, err := parseRemoteURL(remoteURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if baseURL == "" {
+		baseURL = gitHubBaseURL(scheme, host)
+	}
+
+	return &GitHub{
+		client:   &http.Client{Timeout: 30 * time.Second},
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		repoPath: repoPath,
+		token:    token,
+	}, nil
+}
+
+// gitHubBaseURL returns the base URL of the API of github.com or a GitHub Enterprise server.
+func gitHubBaseURL(scheme, host string) string {
+	if host == githubHost {
+		return "https://api.github.com"
+	}
+	return fmt.Sprintf("%s://%s/api/v3", scheme, host)
+}
+
+// OpenPullRequest opens a pull request from the head to the base branch.
+func (g *GitHub) OpenPullRequest(head, base, title, body string) (*PullRequest, error) {
+	var pr struct {
+		Number  int    `json:"number"`
+		HTMLURL string `json:"html_url"`
+	}
+	err := postJSON(g.client, fmt.Sprintf("%s/repos/%s/pulls", g.baseURL, g.repoPath),
+		map[string]string{"Accept": "application/vnd.github.v3+json", "Authorization": "token " + g.token},
+		map[string]string{"title": title, "head": head, "base": base, "body": body},
+		&pr,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening pull request")
+	}
+	return &PullRequest{Number: pr.Number, URL: pr.HTMLURL}, nil
+}
@@ -0,0 +1,93 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package git
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitHubOpenPullRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v3/repos/sapcc/helm-charts/pulls" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("Authorization") != "token secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"message": "Bad credentials"}`)
+			return
+		}
+
+		var req map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		if req["head"] == "exists" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			fmt.Fprint(w, `{"message": "Validation Failed", "errors": [{"message": "A pull request already exists for sapcc:exists."}]}`)
+			return
+		}
+
+		assert.Equal(t, map[string]string{"head": "prometheus-1", "base": "master", "title": "[prometheus] updating dependencies", "body": "details"}, req)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"number": 42, "html_url": "https://github.corp/sapcc/helm-charts/pull/42"}`)
+	}))
+	defer srv.Close()
+
+	gh, err := NewGitHub("git@github.corp:sapcc/helm-charts.git", srv.URL+"/api/v3/", "secret")
+	require.NoError(t, err)
+
+	pr, err := gh.OpenPullRequest("prometheus-1", "master", "[prometheus] updating dependencies", "details")
+	require.NoError(t, err, "there should be no error opening the pull request")
+	assert.Equal(t, &PullRequest{Number: 42, URL: "https://github.corp/sapcc/helm-charts/pull/42"}, pr)
+
+	_, err = gh.OpenPullRequest("exists", "master", "title", "body")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "A pull request already exists for sapcc:exists.")
+
+	gh.token = "wrong"
+	_, err = gh.OpenPullRequest("prometheus-1", "master", "title", "body")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Bad credentials")
+}
+
+func TestNewGitHubBaseURL(t *testing.T) {
+	for remote, baseURL := range map[string]string{
+		"https://github.com/sapcc/helm-charts.git":     "https://api.github.com",
+		"git@github.com:sapcc/helm-charts.git":         "https://api.github.com",
+		"https://github.corp/sapcc/helm-charts":        "https://github.corp/api/v3",
+		"ssh://git@github.corp:2222/sapcc/helm-charts": "https://github.corp/api/v3",
+		"https://github.corp:8443/sapcc/helm-charts":   "https://github.corp:8443/api/v3",
+		"http://github.corp/sapcc/helm-charts.git":     "http://github.corp/api/v3",
+	} {
+		gh, err := NewGitHub(remote, "", "token")
+		require.NoError(t, err, "there should be no error parsing %s", remote)
+		assert.Equal(t, baseURL, gh.baseURL, remote)
+		assert.Equal(t, "sapcc/helm-charts", gh.repoPath, remote)
+	}
+
+	_, err := NewGitHub("helm-charts", "", "token")
+	assert.Error(t, err, "a remote without host should be an error")
+}
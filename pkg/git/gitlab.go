@@ -0,0 +1,79 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package git
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// GitLab opens merge requests using the GitLab REST API.
+type GitLab struct {
+	client *http.Client
+
+	// baseURL of the API like "https://gitlab.com/api/v4".
+	baseURL,
+	repoPath,
+	token string
+}
+
+// NewGitLab returns a new GitLab client for the project of the given remote URL.
+// The base URL of the API is derived from the scheme and host of the remote URL if not given.
+func NewGitLab(remoteURL, baseURL, token string) (*GitLab, error) {
+	scheme, host, repoPath, err := parseRemoteURL(remoteURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if baseURL == "" {
+		baseURL = fmt.Sprintf("%s://%s/api/v4", scheme, host)
+	}
+
+	return &GitLab{
+		client:   &http.Client{Timeout: 30 * time.Second},
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		repoPath: repoPath,
+		token:    token,
+	}, nil
+}
+
+// OpenPullRequest opens a merge request from the head to the base branch.
+// The returned number is the ID of the merge request within the project.
+func (g *GitLab) OpenPullRequest(head, base, title, body string) (*PullRequest, error) {
+	var mr struct {
+		IID    int    `json:"iid"`
+		WebURL string `json:"web_url"`
+	}
+	// Projects are referenced by their URL-encoded path, which may contain nested groups.
+	err := postJSON(g.client, fmt.Sprintf("%s/projects/%s/merge_requests", g.baseURL, url.PathEscape(g.repoPath)),
+		map[string]string{"PRIVATE-TOKEN": g.token},
+		map[string]string{"title": title, "source_branch": head, "target_branch": base, "description": body},
+		&mr,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening merge request")
+	}
+	return &PullRequest{Number: mr.IID, URL: mr.WebURL}, nil
+}
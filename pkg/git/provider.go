@@ -0,0 +1,239 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package git
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// PullRequest is an opened pull request or merge request.
+type PullRequest struct {
+	Number int
+	URL    string
+}
+
+// Provider opens pull requests in the hosted repository.
+type Provider interface {
+	// OpenPullRequest opens a pull request from the head to the base branch.
+	OpenPullRequest(head, base, title, body string) (*PullRequest, error)
+}
+
+// ProviderType is one of ProviderTypes.
+type ProviderType string
+
+// ProviderTypes enumerates the supported hosting providers.
+var ProviderTypes = struct {
+	GitHub,
+	GitLab,
+	Gitea ProviderType
+}{
+	"github",
+	"gitlab",
+	"gitea",
+}
+
+// ParseProviderType parses the given provider, which is one of github, gitlab or gitea.
+func ParseProviderType(providerType string) (ProviderType, error) {
+	switch p := ProviderType(strings.ToLower(providerType)); p {
+	case ProviderTypes.GitHub, ProviderTypes.GitLab, ProviderTypes.Gitea:
+		return p, nil
+	}
+	return "", errors.Errorf("unknown provider %s, must be one of github, gitlab, gitea", providerType)
+}
+
+// DetectProviderType returns the provider of the given remote URL.
+// Hosts containing "gitlab" or "gitea" are GitLab or Gitea, all others GitHub or GitHub Enterprise.
+func DetectProviderType(remoteURL string) (ProviderType, error) {
+	_, host, _, err := parseRemoteURL(remoteURL)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case strings.Contains(host, string(ProviderTypes.GitLab)):
+		return ProviderTypes.GitLab, nil
+	case strings.Contains(host, string(ProviderTypes.Gitea)):
+		return ProviderTypes.Gitea, nil
+	}
+	return ProviderTypes.GitHub, nil
+}
+
+// NewProvider returns the provider of the given type for the repository of the remote URL.
+// The type is detected from the remote URL if not given. The base URL of the API is derived from the remote URL if not given.
+func NewProvider(providerType ProviderType, remoteURL, baseURL, token string) (Provider, error) {
+	if providerType == "" {
+		var err error
+		if providerType, err = DetectProviderType(remoteURL); err != nil {
+			return nil, err
+		}
+	}
+
+	switch providerType {
+	case ProviderTypes.GitLab:
+		return NewGitLab(remoteURL, baseURL, token)
+	case ProviderTypes.Gitea:
+		return NewGitea(remoteURL, baseURL, token)
+	}
+	return NewGitHub(remoteURL, baseURL, token)
+}
+
+// LoadToken returns the content of the given token file or, if not given, the token in the environment variable of the provider:
+// GITHUB_TOKEN, GITLAB_TOKEN or GITEA_TOKEN.
+func LoadToken(tokenFile string, providerType ProviderType) (string, error) {
+	if tokenFile == "" {
+		env := strings.ToUpper(string(providerType)) + "_TOKEN"
+		token, ok := os.LookupEnv(env)
+		if !ok {
+			return "", errors.Errorf("%s environment variable not set", env)
+		}
+		return token, nil
+	}
+
+	data, err := ioutil.ReadFile(tokenFile)
+	if err != nil {
+		return "", errors.Wrap(err, "error reading token file")
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// postJSON posts the request body as JSON and decodes the created resource into the response body.
+func postJSON(client *http.Client, u string, header map[string]string, reqBody, resBody interface{}) error {
+	data, err := json.Marshal(reqBody)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusCreated {
+		return errors.Errorf("%s: %s", res.Status, apiErrorMessage(res))
+	}
+	return errors.Wrap(json.NewDecoder(res.Body).Decode(resBody), "error decoding response")
+}
+
+// apiErrorMessage returns the message and the detailed errors of an error response.
+// GitHub and Gitea return the message as string, GitLab also as list or map.
+func apiErrorMessage(res *http.Response) string {
+	var e struct {
+		Message interface{} `json:"message"`
+		Errors  []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&e); err != nil || e.Message == nil {
+		return "unknown error"
+	}
+
+	var msg []string
+	switch m := e.Message.(type) {
+	case string:
+		msg = append(msg, m)
+	case []interface{}:
+		for _, v := range m {
+			msg = append(msg, fmt.Sprintf("%v", v))
+		}
+	default:
+		msg = append(msg, fmt.Sprintf("%v", m))
+	}
+	for _, d := range e.Errors {
+		if d.Message != "" {
+			msg = append(msg, d.Message)
+		}
+	}
+	return strings.Join(msg, ", ")
+}
+
+// parseRemoteURL returns the scheme and host of the web server and the path of the repository like "sapcc/helm-charts" of a git remote URL.
+// HTTP(S), SSH and scp-like URLs like "git@github.com:sapcc/helm-charts.git" are supported.
+// HTTP(S) remotes keep their scheme and port. The web server of SSH remotes is expected to use HTTPS on the default port.
+func parseRemoteURL(remote string) (string, string, string, error) {
+	remote = strings.TrimSpace(remote)
+	if !strings.Contains(remote, "://") {
+		// scp-like syntax: [user@]host:path
+		i := strings.Index(remote, ":")
+		if i < 0 {
+			return "", "", "", errors.Errorf("invalid git remote URL %s", remote)
+		}
+		remote = "ssh://" + remote[:i] + "/" + remote[i+1:]
+	}
+
+	u, err := url.Parse(remote)
+	if err != nil {
+		return "", "", "", errors.Wrapf(err, "invalid git remote URL %s", remote)
+	}
+
+	repoPath := strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+	if u.Hostname() == "" || !strings.Contains(repoPath, "/") {
+		return "", "", "", errors.Errorf("invalid git remote URL %s", remote)
+	}
+
+	if u.Scheme == "http" || u.Scheme == "https" {
+		return u.Scheme, u.Host, repoPath, nil
+	}
+	return "https", u.Hostname(), repoPath, nil
+}
+
+// pushURL returns the HTTPS URL of the remote with the given credentials.
+// HTTP(S) remotes keep their host and port. SSH remotes are pushed to via HTTPS on the default port.
+func pushURL(remote, user, token string) (string, error) {
+	remote = strings.TrimSpace(remote)
+	if strings.HasPrefix(remote, "https://") || strings.HasPrefix(remote, "http://") {
+		u, err := url.Parse(remote)
+		if err != nil {
+			return "", errors.Wrapf(err, "invalid git remote URL %s", remote)
+		}
+		u.User = url.UserPassword(user, token)
+		return u.String(), nil
+	}
+
+	scheme, host, repoPath, err := parseRemoteURL(remote)
+	if err != nil {
+		return "", err
+	}
+	u := &url.URL{
+		Scheme: scheme,
+		User:   url.UserPassword(user, token),
+		Host:   host,
+		Path:   "/" + repoPath + ".git",
+	}
+	return u.String(), nil
+}
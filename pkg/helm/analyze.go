@@ -0,0 +1,380 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package helm
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/pkg/errors"
+	"k8s.io/helm/pkg/chartutil"
+	helm_env "k8s.io/helm/pkg/helm/environment"
+	"k8s.io/helm/pkg/proto/hapi/chart"
+)
+
+// ValuesChangeType is one of ValuesChangeTypes.
+type ValuesChangeType string
+
+// ValuesChangeTypes enumerates the changes of a default value.
+var ValuesChangeTypes = struct {
+	Added,
+	Removed,
+	Renamed,
+	Changed ValuesChangeType
+}{
+	"added",
+	"removed",
+	"renamed",
+	"changed",
+}
+
+// ValuesChange is the change of a default value of a dependency between its current and latest version.
+type ValuesChange struct {
+	// Key is the path of the value like "image.tag".
+	Key  string           `json:"key" yaml:"key"`
+	Type ValuesChangeType `json:"type" yaml:"type"`
+
+	// RenamedTo is the key a renamed value presumably moved to.
+	RenamedTo string `json:"renamedTo,omitempty" yaml:"renamedTo,omitempty"`
+}
+
+// String returns the change like "image.tag removed" or "rbac.create renamed to rbac.enabled".
+func (c *ValuesChange) String() string {
+	if c.Type == ValuesChangeTypes.Renamed {
+		return fmt.Sprintf("%s renamed to %s", c.Key, c.RenamedTo)
+	}
+	return fmt.Sprintf("%s %s", c.Key, c.Type)
+}
+
+// ValuesAnalysis is the difference between the default values of the current and the latest version of a dependency.
+type ValuesAnalysis struct {
+	// Changes of the default values sorted by key.
+	Changes []*ValuesChange
+
+	// Affected are the values the parent chart sets for the dependency, which were removed or renamed in the latest version.
+	Affected []*ValuesChange
+
+	// SchemaChanges are the breaking changes of the values.schema.json if both versions have one.
+	SchemaChanges []*SchemaChange
+
+	// SchemaErrors are the violations of the values.schema.json of the latest version by the values of the parent chart.
+	SchemaErrors []string
+}
+
+// Summary returns the number of changes by type like "2 added, 1 removed".
+func (a *ValuesAnalysis) Summary() string {
+	if len(a.Changes) == 0 {
+		return "no changes"
+	}
+
+	counts := make(map[ValuesChangeType]int)
+	for _, c := range a.Changes {
+		counts[c.Type]++
+	}
+
+	var res []string
+	for _, t := range []ValuesChangeType{ValuesChangeTypes.Added, ValuesChangeTypes.Removed, ValuesChangeTypes.Renamed, ValuesChangeTypes.Changed} {
+		if counts[t] > 0 {
+			res = append(res, fmt.Sprintf("%d %s", counts[t], t))
+		}
+	}
+	return strings.Join(res, ", ")
+}
+
+// AnalyzeValues downloads the current and latest version of the outdated dependencies and diffs their default values and values schemas.
+// The values of the charts declaring the dependencies are validated against the values schema of the latest version.
+// Dependencies, which could not be analyzed, e.g. charts in OCI registries, are skipped with a warning.
+func AnalyzeValues(results []*Result, helmSettings *helm_env.EnvSettings) error {
+	repos, err := loadRepositories(helmSettings)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		if !r.IsOutdated() || r.Replace {
+			continue
+		}
+
+		a, err := analyzeValues(r, repos, helmSettings)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error analyzing values of %s: %s\n", r.displayName(), err.Error())
+			continue
+		}
+		r.ValuesAnalysis = a
+	}
+	return nil
+}
+
+func analyzeValues(r *Result, repos *repositories, helmSettings *helm_env.EnvSettings) (*ValuesAnalysis, error) {
+	releases, err := findReleasesOfDependency(r.Dependency, repos)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := fetchChart(r.Dependency, r.CurrentVersion, releases, repos, helmSettings)
+	if err != nil {
+		return nil, err
+	}
+	latest, err := fetchChart(r.Dependency, r.LatestVersion, releases, repos, helmSettings)
+	if err != nil {
+		return nil, err
+	}
+
+	currentValues, err := chartutil.ReadValues([]byte(current.GetValues().GetRaw()))
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading values of %s %s", r.Name, r.CurrentVersion)
+	}
+	latestValues, err := chartutil.ReadValues([]byte(latest.GetValues().GetRaw()))
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading values of %s %s", r.Name, r.LatestVersion)
+	}
+
+	a := &ValuesAnalysis{Changes: diffValues(flattenValues(currentValues), flattenValues(latestValues))}
+
+	currentSchema, err := loadValuesSchema(current)
+	if err != nil {
+		return nil, err
+	}
+	latestSchema, err := loadValuesSchema(latest)
+	if err != nil {
+		return nil, err
+	}
+	if currentSchema != nil && latestSchema != nil {
+		a.SchemaChanges = diffSchemas(currentSchema, latestSchema)
+	}
+
+	// The values of subcharts are set by the charts in the charts/ folder, which are not analyzed.
+	if len(r.Path) > 0 {
+		return a, nil
+	}
+
+	parentValues, err := loadParentValues(r)
+	if err != nil {
+		return nil, err
+	}
+	a.Affected = affectedValues(flattenValues(parentValues), a.Changes)
+
+	// The values set by the parent chart override the defaults of the dependency.
+	if latestSchema != nil {
+		latestValues.MergeInto(parentValues)
+		a.SchemaErrors = latestSchema.validate(latestValues)
+	}
+	return a, nil
+}
+
+// IsSchemaInvalid checks whether the values of the parent chart violate the values schema of the latest version.
+func (a *ValuesAnalysis) IsSchemaInvalid() bool {
+	return len(a.SchemaErrors) > 0
+}
+
+// fetchChart loads the given version of the dependency from the archive cache of Helm.
+// The archive is downloaded using the credentials of the repository if it is not cached yet or does not match the digest given by the index.
+func fetchChart(dep *chartutil.Dependency, v *semver.Version, releases *chartReleases, repos *repositories, helmSettings *helm_env.EnvSettings) (*chart.Chart, error) {
+	cv := releases.entry(v)
+	if cv == nil || len(cv.URLs) == 0 {
+		return nil, errors.Errorf("no download URL of chart %s %s found in repository %s", dep.Name, v, dep.Repository)
+	}
+
+	e, err := repos.entry(dep.Repository)
+	if err != nil {
+		return nil, err
+	}
+
+	// Charts with the same name and version in different repositories might differ, so they are cached per repository.
+	archive := filepath.Join(helmSettings.Home.Archive(), normalizeRepoName(e.URL), fmt.Sprintf("%s-%s.tgz", dep.Name, v))
+	if data, err := ioutil.ReadFile(archive); err == nil && verifyChartDigest(data, cv) == nil {
+		return chartutil.LoadArchive(bytes.NewReader(data))
+	}
+
+	data, err := downloadChartVersion(cv, e, repos)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(archive), 0755); err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(archive, data, 0644); err != nil {
+		return nil, err
+	}
+	return chartutil.LoadArchive(bytes.NewReader(data))
+}
+
+// loadParentValues returns the values the chart declaring the dependency sets under its alias or name.
+func loadParentValues(r *Result) (map[string]interface{}, error) {
+	path := filepath.Join(r.ChartPath, chartutil.ValuesfileName)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	vals, err := chartutil.ReadValuesFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading %s", path)
+	}
+
+	name := r.Alias
+	if name == "" {
+		name = r.Name
+	}
+	if v, ok := vals[name].(map[string]interface{}); ok {
+		return v, nil
+	}
+	return nil, nil
+}
+
+// flattenValues returns the leaf values by their path like "image.tag". Lists and empty maps are leaves.
+func flattenValues(vals map[string]interface{}) map[string]interface{} {
+	res := make(map[string]interface{})
+	var flatten func(prefix string, v map[string]interface{})
+	flatten = func(prefix string, v map[string]interface{}) {
+		for k, val := range v {
+			key := prefix + k
+			if m, ok := val.(map[string]interface{}); ok && len(m) > 0 {
+				flatten(key+".", m)
+				continue
+			}
+			res[key] = val
+		}
+	}
+	flatten("", vals)
+	return res
+}
+
+// diffValues returns the changes between the flattened values sorted by key.
+// A removed value is considered renamed if exactly one added value ends with its key or has the same parent and default.
+func diffValues(current, latest map[string]interface{}) []*ValuesChange {
+	var removed, added []string
+	changes := make(map[string]*ValuesChange)
+	for k, v := range current {
+		latestValue, ok := latest[k]
+		switch {
+		case !ok:
+			removed = append(removed, k)
+		case !reflect.DeepEqual(v, latestValue):
+			changes[k] = &ValuesChange{Key: k, Type: ValuesChangeTypes.Changed}
+		}
+	}
+	for k := range latest {
+		if _, ok := current[k]; !ok {
+			added = append(added, k)
+		}
+	}
+	sort.Strings(removed)
+	sort.Strings(added)
+
+	renamed := make(map[string]bool)
+	for _, k := range removed {
+		to := findRename(k, current[k], added, latest, renamed)
+		if to == "" {
+			changes[k] = &ValuesChange{Key: k, Type: ValuesChangeTypes.Removed}
+			continue
+		}
+		renamed[to] = true
+		changes[k] = &ValuesChange{Key: k, Type: ValuesChangeTypes.Renamed, RenamedTo: to}
+	}
+	for _, k := range added {
+		if !renamed[k] {
+			changes[k] = &ValuesChange{Key: k, Type: ValuesChangeTypes.Added}
+		}
+	}
+
+	res := make([]*ValuesChange, 0, len(changes))
+	for _, c := range changes {
+		res = append(res, c)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Key < res[j].Key
+	})
+	return res
+}
+
+// findRename returns the added key the removed one was presumably renamed to or an empty string.
+func findRename(key string, value interface{}, added []string, latest map[string]interface{}, renamed map[string]bool) string {
+	matches := func(match func(k string) bool) string {
+		var res []string
+		for _, k := range added {
+			if !renamed[k] && match(k) {
+				res = append(res, k)
+			}
+		}
+		if len(res) != 1 {
+			return ""
+		}
+		return res[0]
+	}
+
+	// The value moved into a map, e.g. from "image.tag" to "controller.image.tag".
+	if to := matches(func(k string) bool { return strings.HasSuffix(k, "."+key) }); to != "" {
+		return to
+	}
+	// The value was renamed keeping its default, e.g. from "rbac.create" to "rbac.enabled".
+	return matches(func(k string) bool {
+		return parentKey(k) == parentKey(key) && reflect.DeepEqual(latest[k], value)
+	})
+}
+
+// affectedValues returns the removed or renamed values the parent chart sets by the key set by the parent.
+func affectedValues(parentValues map[string]interface{}, changes []*ValuesChange) []*ValuesChange {
+	keys := make([]string, 0, len(parentValues))
+	for k := range parentValues {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var res []*ValuesChange
+	for _, k := range keys {
+		for _, c := range changes {
+			if c.Type != ValuesChangeTypes.Removed && c.Type != ValuesChangeTypes.Renamed {
+				continue
+			}
+
+			// The parent either sets the value itself, a value nested in it or a map containing it.
+			switch {
+			case k == c.Key, strings.HasPrefix(c.Key, k+"."):
+				res = append(res, c)
+			case strings.HasPrefix(k, c.Key+"."):
+				affected := &ValuesChange{Key: k, Type: c.Type}
+				if c.Type == ValuesChangeTypes.Renamed {
+					affected.RenamedTo = c.RenamedTo + strings.TrimPrefix(k, c.Key)
+				}
+				res = append(res, affected)
+			default:
+				continue
+			}
+			break
+		}
+	}
+	return res
+}
+
+// parentKey returns the key of the map containing the value like "image" for "image.tag".
+func parentKey(key string) string {
+	if i := strings.LastIndex(key, "."); i >= 0 {
+		return key[:i]
+	}
+	return ""
+}
@@ -0,0 +1,248 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package helm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Masterminds/semver"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/helm/pkg/chartutil"
+	"k8s.io/helm/pkg/proto/hapi/chart"
+	"k8s.io/helm/pkg/repo"
+)
+
+const (
+	nginxValuesCurrent = `image:
+  repository: nginx
+  tag: "1.17"
+rbac:
+  create: true
+legacy:
+  enabled: false
+service:
+  port: 80
+resources: {}
+replicas: 1
+`
+
+	nginxValuesLatest = `controller:
+  image:
+    repository: nginx
+    tag: "1.19"
+rbac:
+  enabled: true
+metrics:
+  enabled: false
+service:
+  port: 8080
+replicas: 1
+`
+
+	nginxSchemaCurrent = `{
+  "type": "object",
+  "properties": {
+    "replicas": {"type": "integer"},
+    "service": {"type": "object", "properties": {"port": {"type": ["integer", "string"]}}}
+  }
+}`
+
+	nginxSchemaLatest = `{
+  "type": "object",
+  "properties": {
+    "replicas": {"type": "integer", "enum": [1, 3]},
+    "service": {"type": "object", "properties": {"port": {"type": "integer"}}}
+  }
+}`
+
+	nginxIndexFile = `apiVersion: v1
+entries:
+  nginx:
+  - name: nginx
+    version: 2.0.0
+    urls:
+    - nginx-2.0.0.tgz
+  - name: nginx
+    version: 1.0.0
+    urls:
+    - nginx-1.0.0.tgz
+`
+
+	nginxParentValues = `nginx:
+  image:
+    tag: "1.17-custom"
+  rbac:
+    create: false
+  legacy:
+    enabled: true
+  resources:
+    limits:
+      cpu: 100m
+  replicas: 2
+`
+)
+
+// newTestChartRepository serves the nginx chart with a values schema in the versions 1.0.0 and 2.0.0 and counts the downloads.
+func newTestChartRepository(t *testing.T, downloads *int32) *httptest.Server {
+	dir, err := ioutil.TempDir("", "repo")
+	require.NoError(t, err)
+
+	for version, files := range map[string][2]string{"1.0.0": {nginxValuesCurrent, nginxSchemaCurrent}, "2.0.0": {nginxValuesLatest, nginxSchemaLatest}} {
+		c := &chart.Chart{
+			Metadata: &chart.Metadata{ApiVersion: "v1", Name: "nginx", Version: version},
+			Values:   &chart.Config{Raw: files[0]},
+			Files:    []*any.Any{{TypeUrl: valuesSchemaName, Value: []byte(files[1])}},
+		}
+		_, err := chartutil.Save(c, dir)
+		require.NoError(t, err, "there should be no error packaging nginx %s", version)
+	}
+
+	files := http.FileServer(http.Dir(dir))
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(downloads, 1)
+		files.ServeHTTP(w, r)
+	}))
+}
+
+func TestAnalyzeValues(t *testing.T) {
+	var downloads int32
+	srv := newTestChartRepository(t, &downloads)
+	defer srv.Close()
+
+	helmSettings, cleanup := newHelmSettings(t)
+	defer cleanup()
+	require.NoError(t, os.MkdirAll(helmSettings.Home.Cache(), 0755))
+
+	repos, err := loadRepositories(helmSettings)
+	require.NoError(t, err)
+	e, err := repos.entry(srv.URL)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(repos.cacheIndexFile(e), []byte(nginxIndexFile), 0644))
+
+	chartPath, err := ioutil.TempDir("", "umbrella")
+	require.NoError(t, err)
+	defer os.RemoveAll(chartPath)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(chartPath, chartutil.ValuesfileName), []byte(nginxParentValues), 0644))
+
+	r := &Result{
+		Dependency:     &chartutil.Dependency{Name: "nginx", Repository: srv.URL, Version: "1.0.0"},
+		ChartPath:      chartPath,
+		CurrentVersion: semver.MustParse("1.0.0"),
+		LatestVersion:  semver.MustParse("2.0.0"),
+	}
+	require.NoError(t, AnalyzeValues([]*Result{r}, helmSettings), "there should be no error analyzing the values")
+	require.NotNil(t, r.ValuesAnalysis, "the values should be analyzed")
+
+	assert.Equal(t, []*ValuesChange{
+		{Key: "image.repository", Type: ValuesChangeTypes.Renamed, RenamedTo: "controller.image.repository"},
+		{Key: "image.tag", Type: ValuesChangeTypes.Renamed, RenamedTo: "controller.image.tag"},
+		{Key: "legacy.enabled", Type: ValuesChangeTypes.Removed},
+		{Key: "metrics.enabled", Type: ValuesChangeTypes.Added},
+		{Key: "rbac.create", Type: ValuesChangeTypes.Renamed, RenamedTo: "rbac.enabled"},
+		{Key: "resources", Type: ValuesChangeTypes.Removed},
+		{Key: "service.port", Type: ValuesChangeTypes.Changed},
+	}, r.ValuesAnalysis.Changes)
+	assert.Equal(t, "1 added, 2 removed, 3 renamed, 1 changed", r.ValuesAnalysis.Summary())
+
+	assert.Equal(t, []*ValuesChange{
+		{Key: "image.tag", Type: ValuesChangeTypes.Renamed, RenamedTo: "controller.image.tag"},
+		{Key: "legacy.enabled", Type: ValuesChangeTypes.Removed},
+		{Key: "rbac.create", Type: ValuesChangeTypes.Renamed, RenamedTo: "rbac.enabled"},
+		{Key: "resources.limits.cpu", Type: ValuesChangeTypes.Removed},
+	}, r.ValuesAnalysis.Affected, "only removed or renamed values set by the chart should be affected")
+
+	assert.Equal(t, []*SchemaChange{
+		{Key: "replicas", Type: SchemaChangeTypes.Enum, Allowed: []string{"1", "3"}},
+		{Key: "service.port", Type: SchemaChangeTypes.Type, Allowed: []string{"integer"}},
+	}, r.ValuesAnalysis.SchemaChanges)
+	assert.Equal(t, []string{"replicas: 2 is not one of the allowed values"}, r.ValuesAnalysis.SchemaErrors, "the values of the chart should be validated against the latest schema")
+	assert.Equal(t, IncTypes.Major, r.Risk(), "values violating the schema should be treated like a major update")
+
+	// The archives are cached.
+	assert.Equal(t, int32(2), downloads)
+	require.NoError(t, AnalyzeValues([]*Result{r}, helmSettings))
+	assert.Equal(t, int32(2), downloads, "the cached archives should be used")
+}
+
+func TestFetchChartVerifiesDigest(t *testing.T) {
+	var downloads int32
+	srv := newTestChartRepository(t, &downloads)
+	defer srv.Close()
+
+	helmSettings, cleanup := newHelmSettings(t)
+	defer cleanup()
+
+	res, err := http.Get(srv.URL + "/nginx-1.0.0.tgz")
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	require.NoError(t, err)
+	sum := sha256.Sum256(data)
+
+	repos, err := loadRepositories(helmSettings)
+	require.NoError(t, err)
+	dep := &chartutil.Dependency{Name: "nginx", Repository: srv.URL, Version: "1.0.0"}
+	v := semver.MustParse("1.0.0")
+	cv := &repo.ChartVersion{Metadata: &chart.Metadata{Name: "nginx", Version: "1.0.0"}, URLs: []string{"nginx-1.0.0.tgz"}, Digest: hex.EncodeToString(sum[:])}
+	releases := &chartReleases{entries: map[string]*repo.ChartVersion{"1.0.0": cv}}
+
+	// A truncated archive of an earlier download is in the cache.
+	e, err := repos.entry(srv.URL)
+	require.NoError(t, err)
+	archive := filepath.Join(helmSettings.Home.Archive(), normalizeRepoName(e.URL), "nginx-1.0.0.tgz")
+	require.NoError(t, os.MkdirAll(filepath.Dir(archive), 0755))
+	require.NoError(t, ioutil.WriteFile(archive, data[:len(data)/2], 0644))
+
+	atomic.StoreInt32(&downloads, 0)
+	c, err := fetchChart(dep, v, releases, repos, helmSettings)
+	require.NoError(t, err, "there should be no error fetching the chart")
+	assert.Equal(t, "1.0.0", c.GetMetadata().GetVersion())
+	assert.Equal(t, int32(1), downloads, "an archive not matching the digest should be downloaded again")
+
+	_, err = fetchChart(dep, v, releases, repos, helmSettings)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), downloads, "the cached archive matching the digest should be used")
+
+	cv.Digest = strings.Repeat("0", 64)
+	_, err = fetchChart(dep, v, releases, repos, helmSettings)
+	assert.Error(t, err, "an archive not matching the digest should be rejected")
+}
+
+func TestAffectedValuesOfReplacedMap(t *testing.T) {
+	affected := affectedValues(
+		flattenValues(map[string]interface{}{"image": map[string]interface{}{"tag": "1.0"}}),
+		diffValues(
+			flattenValues(map[string]interface{}{"image": map[string]interface{}{"tag": "1.0"}}),
+			flattenValues(map[string]interface{}{"image": "nginx:1.0"}),
+		),
+	)
+	assert.Equal(t, []*ValuesChange{{Key: "image.tag", Type: ValuesChangeTypes.Removed}}, affected)
+}
@@ -0,0 +1,264 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package helm
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/helm/pkg/getter"
+	helm_env "k8s.io/helm/pkg/helm/environment"
+	"k8s.io/helm/pkg/tlsutil"
+	"k8s.io/helm/pkg/version"
+)
+
+const (
+	pluginConfigName = "outdated-dependencies.yaml"
+
+	// pluginConfigEnv overrides the path of the plugin configuration.
+	pluginConfigEnv = "HELM_OUTDATED_DEPENDENCIES_CONFIG"
+)
+
+// PluginConfig is the configuration of the plugin.
+// It is read from the outdated-dependencies.yaml in the Helm home or the file given by $HELM_OUTDATED_DEPENDENCIES_CONFIG.
+type PluginConfig struct {
+	Repositories []*RepositoryAuth `json:"repositories,omitempty"`
+
+	// Replacements of deprecated charts.
+	Replacements []*ChartReplacement `json:"replacements,omitempty"`
+}
+
+// RepositoryAuth are the credentials of a chart repository.
+// Environment variables like ${CHARTS_PASSWORD} in the username, password and token are expanded.
+type RepositoryAuth struct {
+	// URL of the repository. The credentials are used for every URL with the same scheme and host and a path below it.
+	URL string `json:"url"`
+
+	// Username and Password for basic authentication.
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+
+	// Token for bearer authentication. Takes precedence over basic authentication.
+	Token string `json:"token,omitempty"`
+
+	// CertFile and KeyFile of the client certificate and CAFile to verify the server certificate.
+	CertFile string `json:"certFile,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty"`
+	CAFile   string `json:"caFile,omitempty"`
+}
+
+// loadPluginConfig loads the configuration of the plugin. An empty configuration is returned if there is none.
+func loadPluginConfig(helmSettings *helm_env.EnvSettings) (*PluginConfig, error) {
+	path, ok := os.LookupEnv(pluginConfigEnv)
+	if !ok {
+		path = helmSettings.Home.Path(pluginConfigName)
+	}
+
+	cfg := &PluginConfig{}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return nil, err
+	}
+
+	if err := fromYaml(data, cfg); err != nil {
+		return nil, errors.Wrapf(err, "error parsing %s", path)
+	}
+
+	for _, a := range cfg.Repositories {
+		if a.URL == "" {
+			return nil, errors.Errorf("error parsing %s: url is required", path)
+		}
+		a.Username, a.Password, a.Token = os.ExpandEnv(a.Username), os.ExpandEnv(a.Password), os.ExpandEnv(a.Token)
+	}
+
+	for _, cr := range cfg.Replacements {
+		if err := cr.validate(); err != nil {
+			return nil, errors.Wrapf(err, "error parsing %s", path)
+		}
+	}
+	return cfg, nil
+}
+
+// authFor returns the credentials for the given URL or nil if there are none.
+// The credentials of the plugin configuration take precedence over the ones in the repositories.yaml.
+// If several repositories match, the one with the longest URL wins.
+func (r *repositories) authFor(u string) *RepositoryAuth {
+	var auth *RepositoryAuth
+	for _, a := range r.auth {
+		if isURLWithin(u, a.URL) && (auth == nil || len(a.URL) > len(auth.URL)) {
+			auth = a
+		}
+	}
+	if auth != nil {
+		return auth
+	}
+
+	for _, e := range r.entries {
+		if isURLWithin(u, e.URL) && (auth == nil || len(e.URL) > len(auth.URL)) {
+			auth = &RepositoryAuth{
+				URL:      e.URL,
+				Username: e.Username,
+				Password: e.Password,
+				CertFile: e.CertFile,
+				KeyFile:  e.KeyFile,
+				CAFile:   e.CAFile,
+			}
+		}
+	}
+	return auth
+}
+
+// isURLWithin checks whether the URL belongs to the repository with the given URL.
+// Both must have the same scheme and host and the path must be the one of the repository or below it, so credentials are never sent to other hosts.
+func isURLWithin(u, repositoryURL string) bool {
+	parsedURL, err := url.Parse(u)
+	if err != nil {
+		return false
+	}
+	parsedRepoURL, err := url.Parse(repositoryURL)
+	if err != nil || parsedRepoURL.Host == "" {
+		return false
+	}
+
+	if !strings.EqualFold(parsedURL.Scheme, parsedRepoURL.Scheme) || !strings.EqualFold(parsedURL.Host, parsedRepoURL.Host) {
+		return false
+	}
+
+	repoPath := strings.TrimSuffix(parsedRepoURL.Path, "/")
+	return parsedURL.Path == repoPath || strings.HasPrefix(parsedURL.Path, repoPath+"/")
+}
+
+// getters returns the getters of Helm with the one for http(s) replaced by one using the credentials of the repositories.
+func (r *repositories) getters(helmSettings *helm_env.EnvSettings) getter.Providers {
+	providers := getter.Providers{{
+		Schemes: []string{"http", "https"},
+		New:     r.newAuthGetter,
+	}}
+
+	for _, p := range getter.All(*helmSettings) {
+		if !p.Provides("http") && !p.Provides("https") {
+			providers = append(providers, p)
+		}
+	}
+	return providers
+}
+
+// authGetter gets http(s) URLs authenticating with the credentials of the repository.
+type authGetter struct {
+	client *http.Client
+	auth   *RepositoryAuth
+
+	// ctx of the requests, e.g. for timeouts. Optional.
+	ctx context.Context
+}
+
+// newAuthGetter is a getter.Constructor. Certificates not passed by Helm are taken from the credentials of the repository.
+func (r *repositories) newAuthGetter(u, certFile, keyFile, caFile string) (getter.Getter, error) {
+	auth := r.authFor(u)
+	if auth != nil {
+		if certFile == "" && keyFile == "" {
+			certFile, keyFile = auth.CertFile, auth.KeyFile
+		}
+		if caFile == "" {
+			caFile = auth.CAFile
+		}
+	}
+
+	tr := &http.Transport{
+		DisableCompression: true,
+		Proxy:              http.ProxyFromEnvironment,
+	}
+	if (certFile != "" && keyFile != "") || caFile != "" {
+		tlsConf, err := tlsutil.NewTLSConfig(u, certFile, keyFile, caFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "can't create TLS config")
+		}
+		tr.TLSClientConfig = tlsConf
+	}
+
+	return &authGetter{client: &http.Client{Transport: tr}, auth: auth}, nil
+}
+
+// Get performs a GET request and returns the body.
+func (g *authGetter) Get(href string) (*bytes.Buffer, error) {
+	buf, _, err := g.getIfModified(href, nil)
+	return buf, err
+}
+
+// getIfModified performs a GET request, which is conditional if validators are given.
+// If the resource was not modified, nil is returned along with the given validators.
+// Otherwise the body is returned along with the new validators.
+func (g *authGetter) getIfModified(href string, validators *cacheValidators) (*bytes.Buffer, *cacheValidators, error) {
+	req, err := http.NewRequest(http.MethodGet, href, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("User-Agent", "Helm/"+strings.TrimPrefix(version.GetVersion(), "v"))
+	if g.ctx != nil {
+		req = req.WithContext(g.ctx)
+	}
+
+	if g.auth != nil {
+		if g.auth.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+g.auth.Token)
+		} else if g.auth.Username != "" && g.auth.Password != "" {
+			req.SetBasicAuth(g.auth.Username, g.auth.Password)
+		}
+	}
+
+	if validators != nil {
+		if validators.ETag != "" {
+			req.Header.Set("If-None-Match", validators.ETag)
+		}
+		if validators.LastModified != "" {
+			req.Header.Set("If-Modified-Since", validators.LastModified)
+		}
+	}
+
+	res, err := g.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotModified && validators != nil {
+		return nil, validators, nil
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, nil, &statusError{URL: href, Status: res.Status, StatusCode: res.StatusCode}
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if _, err = io.Copy(buf, res.Body); err != nil {
+		return nil, nil, err
+	}
+	return buf, &cacheValidators{ETag: res.Header.Get("ETag"), LastModified: res.Header.Get("Last-Modified")}, nil
+}
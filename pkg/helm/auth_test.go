@@ -0,0 +1,113 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package helm
+
+import (
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthGetter(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		switch {
+		case r.URL.Path == "/basic/index.yaml" && ok && user == "admin" && password == "secret":
+		case r.URL.Path == "/bearer/index.yaml" && r.Header.Get("Authorization") == "Bearer token":
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, "apiVersion: v1\n")
+	}))
+	defer srv.Close()
+
+	helmSettings, cleanup := newHelmSettings(t)
+	defer cleanup()
+
+	// The server certificate is trusted via the CA file of the repository.
+	caFile := helmSettings.Home.Path("ca.pem")
+	require.NoError(t, ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0644))
+
+	os.Setenv("CHARTS_PASSWORD", "secret")
+	defer os.Unsetenv("CHARTS_PASSWORD")
+
+	pluginConfig := fmt.Sprintf(`repositories:
+- url: %[1]s/basic
+  username: admin
+  password: ${CHARTS_PASSWORD}
+  caFile: %[2]s
+- url: %[1]s/bearer
+  token: token
+  caFile: %[2]s
+`, srv.URL, caFile)
+	require.NoError(t, ioutil.WriteFile(helmSettings.Home.Path(pluginConfigName), []byte(pluginConfig), 0644))
+
+	repos, err := loadRepositories(helmSettings)
+	require.NoError(t, err, "there should be no error loading the repositories")
+
+	for _, path := range []string{"/basic/index.yaml", "/bearer/index.yaml"} {
+		g, err := repos.newAuthGetter(srv.URL+path, "", "", "")
+		require.NoError(t, err, "there should be no error creating the getter")
+
+		buf, err := g.Get(srv.URL + path)
+		require.NoError(t, err, "there should be no error getting %s", path)
+		assert.Equal(t, "apiVersion: v1\n", buf.String())
+	}
+
+	g, err := repos.newAuthGetter(srv.URL+"/other/index.yaml", "", "", caFile)
+	require.NoError(t, err)
+	_, err = g.Get(srv.URL + "/other/index.yaml")
+	assert.Error(t, err, "URLs without credentials should not be authenticated")
+}
+
+func TestAuthFor(t *testing.T) {
+	helmSettings, cleanup := newHelmSettings(t)
+	defer cleanup()
+
+	repos, err := loadRepositories(helmSettings)
+	require.NoError(t, err)
+	repos.entries[1].Username, repos.entries[1].Password = "admin", "secret"
+	repos.auth = []*RepositoryAuth{{URL: "https://charts.evil.corp/internal", Token: "token"}}
+
+	auth := repos.authFor("https://charts.evil.corp/charts/internal-1.0.0.tgz")
+	require.NotNil(t, auth, "the credentials of the repositories.yaml should be used")
+	assert.Equal(t, "admin", auth.Username)
+
+	auth = repos.authFor("https://charts.evil.corp/internal/index.yaml")
+	require.NotNil(t, auth, "the credentials of the plugin configuration should be used")
+	assert.Equal(t, "token", auth.Token)
+
+	assert.Nil(t, repos.authFor("https://repo.evil.corp/index.yaml"), "unknown repositories should have no credentials")
+	assert.Nil(t, repos.authFor("https://charts.evil.corp.attacker.com/index.yaml"), "hosts starting with the host of a repository should have no credentials")
+	assert.Nil(t, repos.authFor("http://charts.evil.corp/index.yaml"), "other schemes should have no credentials")
+
+	auth = repos.authFor("https://charts.evil.corp/internal2/index.yaml")
+	require.NotNil(t, auth)
+	assert.Empty(t, auth.Token, "paths starting with the path of a repository should not get its credentials")
+	assert.Equal(t, "admin", auth.Username)
+}
@@ -0,0 +1,332 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package helm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"k8s.io/helm/pkg/chartutil"
+	helm_env "k8s.io/helm/pkg/helm/environment"
+	"k8s.io/helm/pkg/proto/hapi/chart"
+	"k8s.io/helm/pkg/repo"
+	"k8s.io/helm/pkg/resolver"
+)
+
+const (
+	apiVersionV2         = "v2"
+	chartLockName        = "Chart.lock"
+	requirementsLockName = "requirements.lock"
+)
+
+// isAPIVersionV2 checks whether the chart declares its dependencies in the Chart.yaml (apiVersion v2).
+func isAPIVersionV2(c *chart.Chart) bool {
+	return c.GetMetadata().GetApiVersion() == apiVersionV2
+}
+
+// requirementsFileName returns the name of the file the dependencies of the chart are declared in.
+func requirementsFileName(c *chart.Chart) string {
+	if isAPIVersionV2(c) {
+		return chartMetadataName
+	}
+	return requirementsName
+}
+
+// lockFileName returns the name of the file the dependencies of the chart are locked in.
+func lockFileName(c *chart.Chart) string {
+	if isAPIVersionV2(c) {
+		return chartLockName
+	}
+	return requirementsLockName
+}
+
+// loadRequirements loads the dependencies from the requirements.yaml (apiVersion v1) or the Chart.yaml (apiVersion v2).
+func loadRequirements(chartPath string, c *chart.Chart) (*chartutil.Requirements, error) {
+	if !isAPIVersionV2(c) {
+		return chartutil.LoadRequirements(c)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(chartPath, chartMetadataName))
+	if err != nil {
+		return nil, err
+	}
+
+	reqs := &chartutil.Requirements{}
+	if err := fromYaml(data, reqs); err != nil {
+		return nil, err
+	}
+
+	if len(reqs.Dependencies) == 0 {
+		return nil, chartutil.ErrRequirementsNotFound
+	}
+	return reqs, nil
+}
+
+// syncChartLock resolves the dependencies of an apiVersion v2 chart, writes the Chart.lock and downloads the locked charts to the charts/ folder.
+// The repository indices are expected to be in the cache already.
+func syncChartLock(chartPath string, reqs *chartutil.Requirements, helmSettings *helm_env.EnvSettings) error {
+	repos, err := loadRepositories(helmSettings)
+	if err != nil {
+		return err
+	}
+
+	lock, err := resolveLock(chartPath, reqs, true, repos)
+	if err != nil {
+		return err
+	}
+
+	data, err := toYamlWithIndent(lock, 0)
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(filepath.Join(chartPath, chartLockName), data, 0644); err != nil {
+		return err
+	}
+	return vendorCharts(chartPath, lock, repos)
+}
+
+// resolveLock resolves the dependencies and returns the requirements.lock or, for apiVersion v2, the Chart.lock.
+// The repository indices are expected to be in the cache already.
+func resolveLock(chartPath string, reqs *chartutil.Requirements, isV2 bool, repos *repositories) (*chartutil.RequirementsLock, error) {
+	// The versions are resolved from the cached indices of the repositories, which might have a custom cache file.
+	lock := &chartutil.RequirementsLock{
+		Generated:    time.Now(),
+		Dependencies: make([]*chartutil.Dependency, 0, len(reqs.Dependencies)),
+	}
+	for _, dep := range reqs.Dependencies {
+		lockedDep, err := resolveDependency(chartPath, dep, repos)
+		if err != nil {
+			return nil, err
+		}
+		lock.Dependencies = append(lock.Dependencies, lockedDep)
+	}
+
+	var err error
+	if isV2 {
+		lock.Digest, err = hashChartLock(reqs, lock)
+	} else {
+		lock.Digest, err = resolver.HashReq(reqs)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return lock, nil
+}
+
+// requirementsLockContent renders the requirements.lock of an apiVersion v1 chart the way Helm 2 writes it.
+// Like Helm 2, the given old content is kept if the digest of the requirements did not change.
+func requirementsLockContent(oldContent []byte, lock *chartutil.RequirementsLock) ([]byte, error) {
+	oldLock := &chartutil.RequirementsLock{}
+	if len(oldContent) > 0 && yaml.Unmarshal(oldContent, oldLock) == nil && oldLock.Digest == lock.Digest {
+		return oldContent, nil
+	}
+	return yaml.Marshal(lock)
+}
+
+// hashChartLock computes the digest of the Chart.lock the same way Helm 3 does,
+// so `helm dependency build` does not consider the lock out of sync.
+func hashChartLock(reqs *chartutil.Requirements, lock *chartutil.RequirementsLock) (string, error) {
+	data, err := json.Marshal([2][]*chartutil.Dependency{reqs.Dependencies, lock.Dependencies})
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// resolveDependency locks the dependency to the latest version matching its version or constraint.
+// The versions are the ones in the cached index of its repository, the tags in its OCI registry or, for local dependencies, the version in the Chart.yaml.
+func resolveDependency(chartPath string, dep *chartutil.Dependency, repos *repositories) (*chartutil.Dependency, error) {
+	// Local dependencies are relative to the chart declaring them.
+	lookupDep := dep
+	if strings.HasPrefix(dep.Repository, filePrefix) {
+		lookupDep = &chartutil.Dependency{Name: dep.Name, Repository: filePrefix + localChartPath(chartPath, dep.Repository)}
+	}
+
+	versions, err := findVersionsOfDependency(lookupDep, repos)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error resolving dependency %s", dep.Name)
+	}
+
+	v, _, err := resolveVersion(dep.Version, versions)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error resolving dependency %s", dep.Name)
+	}
+	if !containsVersion(versions, v) {
+		return nil, errors.Errorf("error resolving dependency %s: version %s not found in repository %s", dep.Name, dep.Version, dep.Repository)
+	}
+
+	return &chartutil.Dependency{
+		Name:       dep.Name,
+		Repository: dep.Repository,
+		Version:    v.Original(),
+	}, nil
+}
+
+func containsVersion(versions semver.Collection, v *semver.Version) bool {
+	for _, version := range versions {
+		if version.Equal(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// vendorCharts downloads the locked dependencies to the charts/ folder like `helm dependency update` does for apiVersion v1 charts.
+// Charts in OCI registries are pulled and local dependencies are packaged. The archives of other versions of the dependencies are removed.
+func vendorCharts(chartPath string, lock *chartutil.RequirementsLock, repos *repositories) error {
+	chartsPath := filepath.Join(chartPath, chartsDirName)
+	if err := os.MkdirAll(chartsPath, 0755); err != nil {
+		return err
+	}
+
+	for _, dep := range lock.Dependencies {
+		if err := removeVendoredChart(chartsPath, dep.Name); err != nil {
+			return err
+		}
+
+		if strings.HasPrefix(dep.Repository, filePrefix) {
+			c, err := chartutil.LoadDir(localChartPath(chartPath, dep.Repository))
+			if err != nil {
+				return errors.Wrapf(err, "error loading local dependency %s", dep.Name)
+			}
+			if _, err := chartutil.Save(c, chartsPath); err != nil {
+				return errors.Wrapf(err, "error packaging local dependency %s", dep.Name)
+			}
+			continue
+		}
+
+		var (
+			data []byte
+			err  error
+		)
+		if isOCIRepository(dep.Repository) {
+			data, err = defaultOCIClient.pullChart(dep.Repository, dep.Name, dep.Version, repos.authFor(dep.Repository))
+		} else {
+			data, err = downloadChart(dep, repos)
+		}
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(filepath.Join(chartsPath, fmt.Sprintf("%s-%s.tgz", dep.Name, dep.Version)), data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeVendoredChart removes the archives of the chart with the given name from the charts/ folder.
+func removeVendoredChart(chartsPath, name string) error {
+	archives, err := filepath.Glob(filepath.Join(chartsPath, name+"-*.tgz"))
+	if err != nil {
+		return err
+	}
+
+	for _, archive := range archives {
+		// The glob also matches charts with the same prefix like "redis-ha" for "redis".
+		c, err := chartutil.Load(archive)
+		if err != nil || c.GetMetadata().GetName() != name {
+			continue
+		}
+		if err := os.Remove(archive); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// downloadChart downloads the archive of the locked dependency using the credentials of its repository.
+func downloadChart(dep *chartutil.Dependency, repos *repositories) ([]byte, error) {
+	e, err := repos.entry(dep.Repository)
+	if err != nil {
+		return nil, err
+	}
+
+	idx, err := repo.LoadIndexFile(repos.cacheIndexFile(e))
+	if err != nil {
+		return nil, err
+	}
+
+	cv, err := idx.Get(dep.Name, dep.Version)
+	if err != nil {
+		return nil, errors.Errorf("chart %s %s not found in repository %s", dep.Name, dep.Version, dep.Repository)
+	}
+	return downloadChartVersion(cv, e, repos)
+}
+
+// downloadChartVersion downloads the archive of the chart version in the repository using its credentials and verifies the digest given by the index.
+func downloadChartVersion(cv *repo.ChartVersion, e *repo.Entry, repos *repositories) ([]byte, error) {
+	if len(cv.URLs) == 0 {
+		return nil, errors.Errorf("no download URL of chart %s %s found in repository %s", cv.GetName(), cv.GetVersion(), e.URL)
+	}
+
+	u, err := repo.ResolveReferenceURL(e.URL, cv.URLs[0])
+	if err != nil {
+		return nil, err
+	}
+
+	g, err := repos.newAuthGetter(u, "", "", "")
+	if err != nil {
+		return nil, err
+	}
+	buf, err := g.Get(u)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error downloading chart %s %s", cv.GetName(), cv.GetVersion())
+	}
+
+	if err := verifyChartDigest(buf.Bytes(), cv); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// verifyChartDigest checks the archive against the SHA-256 digest of the chart version in the index, if there is one.
+func verifyChartDigest(data []byte, cv *repo.ChartVersion) error {
+	if cv.Digest == "" {
+		return nil
+	}
+
+	sum := sha256.Sum256(data)
+	if digest := hex.EncodeToString(sum[:]); !strings.EqualFold(digest, strings.TrimPrefix(cv.Digest, "sha256:")) {
+		return errors.Errorf("digest %s of chart %s %s does not match %s given by the repository index", digest, cv.GetName(), cv.GetVersion(), cv.Digest)
+	}
+	return nil
+}
+
+// localChartPath returns the path of a local dependency, which is relative to the chart declaring it.
+func localChartPath(chartPath, repository string) string {
+	path := strings.TrimPrefix(repository, filePrefix)
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(chartPath, path)
+}
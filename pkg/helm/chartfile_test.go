@@ -0,0 +1,150 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package helm
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/helm/pkg/chartutil"
+	"k8s.io/helm/pkg/proto/hapi/chart"
+)
+
+const chartFileV2 = `apiVersion: v2
+name: umbrella
+version: 1.0.0
+type: application
+dependencies:
+  - name: testdependency
+    repository: https://repo.evil.corp
+    version: 0.0.1
+`
+
+func TestLoadAndWriteDependenciesAPIVersionV2(t *testing.T) {
+	chartPath, err := ioutil.TempDir("", "chart")
+	require.NoError(t, err, "there must be no error creating the chart directory")
+	defer os.RemoveAll(chartPath)
+
+	require.NoError(t, ioutil.WriteFile(path.Join(chartPath, chartMetadataName), []byte(chartFileV2), 0644), "there must be no error writing the Chart.yaml")
+
+	c := &chart.Chart{Metadata: &chart.Metadata{ApiVersion: apiVersionV2}}
+	reqs, err := loadRequirements(chartPath, c)
+	require.NoError(t, err, "there should be no error loading the dependencies from the Chart.yaml")
+	require.Len(t, reqs.Dependencies, 1, "there should be exactly one dependency")
+	assert.Equal(t, "testdependency", reqs.Dependencies[0].Name)
+	assert.Equal(t, "0.0.1", reqs.Dependencies[0].Version)
+
+	err = writeDependencyVersions(chartPath, requirementsFileName(c), []dependencyVersion{{name: "testdependency", version: "0.0.2"}})
+	require.NoError(t, err, "there should be no error writing the dependencies to the Chart.yaml")
+
+	updatedReqs, err := loadRequirements(chartPath, c)
+	require.NoError(t, err, "there should be no error loading the updated dependencies from the Chart.yaml")
+	assert.Equal(t, "0.0.2", updatedReqs.Dependencies[0].Version)
+
+	require.NoError(t, writeChartVersion(chartPath, "1.0.1"), "there should be no error writing the chart version")
+
+	data, err := ioutil.ReadFile(path.Join(chartPath, chartMetadataName))
+	require.NoError(t, err)
+	assert.Equal(t, strings.Replace(strings.Replace(chartFileV2, "version: 1.0.0", "version: 1.0.1", 1), "version: 0.0.1", "version: 0.0.2", 1), string(data))
+}
+
+func TestSyncChartLockVendorsCharts(t *testing.T) {
+	var downloads int32
+	srv := newTestChartRepository(t, &downloads)
+	defer srv.Close()
+
+	helmSettings, cleanup := newHelmSettings(t)
+	defer cleanup()
+	require.NoError(t, os.MkdirAll(helmSettings.Home.Cache(), 0755))
+
+	repos, err := loadRepositories(helmSettings)
+	require.NoError(t, err)
+	e, err := repos.entry(srv.URL)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(repos.cacheIndexFile(e), []byte(nginxIndexFile), 0644))
+
+	dir, err := ioutil.TempDir("", "charts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"sub/Chart.yaml":      "apiVersion: v2\nname: sub\nversion: 0.2.0\n",
+		"umbrella/Chart.yaml": "apiVersion: v2\nname: umbrella\nversion: 1.0.0\ndependencies:\n  - name: nginx\n    repository: " + srv.URL + "\n    version: 2.0.0\n  - name: sub\n    repository: file://../sub\n    version: 0.2.0\n",
+	}
+	for name, content := range files {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	chartPath := filepath.Join(dir, "umbrella")
+
+	// The previous version of nginx and an unrelated chart are vendored already.
+	chartsPath := filepath.Join(chartPath, chartsDirName)
+	require.NoError(t, os.MkdirAll(chartsPath, 0755))
+	for _, m := range []*chart.Metadata{{Name: "nginx", Version: "1.0.0"}, {Name: "nginx-ingress", Version: "1.0.0"}} {
+		_, err := chartutil.Save(&chart.Chart{Metadata: m}, chartsPath)
+		require.NoError(t, err)
+	}
+
+	c := &chart.Chart{Metadata: &chart.Metadata{ApiVersion: apiVersionV2}}
+	reqs, err := loadRequirements(chartPath, c)
+	require.NoError(t, err)
+	require.NoError(t, syncChartLock(chartPath, reqs, helmSettings), "there should be no error syncing the Chart.lock")
+
+	lock, err := ioutil.ReadFile(filepath.Join(chartPath, chartLockName))
+	require.NoError(t, err)
+	assert.Contains(t, string(lock), "version: 2.0.0")
+
+	archives, err := filepath.Glob(filepath.Join(chartsPath, "*.tgz"))
+	require.NoError(t, err)
+	for i, archive := range archives {
+		archives[i] = filepath.Base(archive)
+	}
+	assert.Equal(t, []string{"nginx-2.0.0.tgz", "nginx-ingress-1.0.0.tgz", "sub-0.2.0.tgz"}, archives, "the charts/ folder should match the Chart.lock")
+}
+
+func TestResolveLockUsesCacheOfRepository(t *testing.T) {
+	helmSettings, cleanup := newHelmSettings(t)
+	defer cleanup()
+
+	// The index is cached in a custom file outside of the cache directory.
+	cacheFile := filepath.Join(string(helmSettings.Home), "internal-index.yaml")
+	repositories := "apiVersion: v1\nrepositories:\n- name: internal\n  url: https://charts.evil.corp\n  cache: " + cacheFile + "\n"
+	require.NoError(t, ioutil.WriteFile(helmSettings.Home.RepositoryFile(), []byte(repositories), 0644))
+	require.NoError(t, ioutil.WriteFile(cacheFile, []byte(nginxIndexFile), 0644))
+
+	repos, err := loadRepositories(helmSettings)
+	require.NoError(t, err)
+
+	reqs := &chartutil.Requirements{Dependencies: []*chartutil.Dependency{{Name: "nginx", Repository: "@internal", Version: "^1.0.0"}}}
+	lock, err := resolveLock("", reqs, true, repos)
+	require.NoError(t, err, "there should be no error resolving the dependencies from the custom cache file")
+	require.Len(t, lock.Dependencies, 1)
+	assert.Equal(t, &chartutil.Dependency{Name: "nginx", Repository: "@internal", Version: "1.0.0"}, lock.Dependencies[0])
+
+	reqs.Dependencies[0].Version = "1.5.0"
+	_, err = resolveLock("", reqs, true, repos)
+	assert.Error(t, err, "a version missing in the index should not be locked")
+}
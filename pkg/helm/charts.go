@@ -0,0 +1,60 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package helm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FindCharts walks the given directory tree and returns the paths of all charts found.
+// Hidden directories and subcharts in the charts/ folder of a chart are skipped.
+func FindCharts(rootPath string) ([]string, error) {
+	var chartPaths []string
+	err := filepath.Walk(rootPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() {
+			return nil
+		}
+
+		if path != rootPath && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+
+		if info.Name() == "charts" && isChartDir(filepath.Dir(path)) {
+			return filepath.SkipDir
+		}
+
+		if isChartDir(path) {
+			chartPaths = append(chartPaths, path)
+		}
+		return nil
+	})
+	return chartPaths, err
+}
+
+func isChartDir(path string) bool {
+	fi, err := os.Stat(filepath.Join(path, chartMetadataName))
+	return err == nil && !fi.IsDir()
+}
@@ -0,0 +1,109 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package helm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newChartTree creates the given files in a temporary directory and returns its path.
+func newChartTree(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "monorepo")
+	require.NoError(t, err, "there must be no error creating the root directory")
+
+	for name, content := range files {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	return dir
+}
+
+func TestFindCharts(t *testing.T) {
+	rootPath := newChartTree(t, map[string]string{
+		"Chart.yaml":                                   "apiVersion: v1\nname: root\nversion: 1.0.0\n",
+		"openstack/keystone/Chart.yaml":                "apiVersion: v1\nname: keystone\nversion: 1.0.0\n",
+		"openstack/keystone/charts/mariadb/Chart.yaml": "apiVersion: v1\nname: mariadb\nversion: 1.0.0\n",
+		"system/monitoring/prometheus/Chart.yaml":      "apiVersion: v2\nname: prometheus\nversion: 1.0.0\n",
+		"system/.git/Chart.yaml":                       "apiVersion: v1\nname: hidden\nversion: 1.0.0\n",
+		".github/chart/Chart.yaml":                     "apiVersion: v1\nname: hidden\nversion: 1.0.0\n",
+		"docs/README.md":                               "no chart\n",
+		"system/charts/ingress/Chart.yaml":             "apiVersion: v1\nname: ingress\nversion: 1.0.0\n",
+	})
+	defer os.RemoveAll(rootPath)
+
+	chartPaths, err := FindCharts(rootPath)
+	require.NoError(t, err, "there should be no error finding the charts")
+	assert.Equal(t, []string{
+		rootPath,
+		filepath.Join(rootPath, "openstack/keystone"),
+		filepath.Join(rootPath, "system/charts/ingress"),
+		filepath.Join(rootPath, "system/monitoring/prometheus"),
+	}, chartPaths, "nested charts should be found, but not hidden directories or the subcharts in the charts/ folder of a chart")
+}
+
+func TestListOutdatedDependenciesOfChartsDownloadsIndexOnce(t *testing.T) {
+	var downloads int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/index.yaml" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		atomic.AddInt32(&downloads, 1)
+		fmt.Fprint(w, nginxIndexFile)
+	}))
+	defer srv.Close()
+
+	helmSettings, cleanup := newHelmSettings(t)
+	defer cleanup()
+	require.NoError(t, os.MkdirAll(helmSettings.Home.Cache(), 0755))
+
+	requirements := "dependencies:\n  - name: nginx\n    repository: " + srv.URL + "\n    version: 1.0.0\n"
+	rootPath := newChartTree(t, map[string]string{
+		"a/Chart.yaml":               "apiVersion: v1\nname: a\nversion: 1.0.0\n",
+		"a/requirements.yaml":        requirements,
+		"b/Chart.yaml":               "apiVersion: v1\nname: b\nversion: 1.0.0\n",
+		"b/requirements.yaml":        requirements,
+		"nested/c/Chart.yaml":        "apiVersion: v1\nname: c\nversion: 1.0.0\n",
+		"nested/c/requirements.yaml": requirements,
+	})
+	defer os.RemoveAll(rootPath)
+
+	chartPaths, err := FindCharts(rootPath)
+	require.NoError(t, err)
+	require.Len(t, chartPaths, 3)
+
+	res, err := ListOutdatedDependenciesOfCharts(chartPaths, helmSettings, &Filter{}, nil)
+	require.NoError(t, err, "there should be no error listing the outdated dependencies")
+	require.Len(t, res, 3, "the outdated dependency of every chart should be listed")
+	for _, r := range res {
+		assert.Equal(t, "2.0.0", r.LatestVersion.String())
+	}
+	assert.Equal(t, int32(1), downloads, "the index of the repository should be downloaded only once for all charts")
+}
@@ -28,12 +28,12 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
-	"sync"
+	"time"
 
 	"github.com/Masterminds/semver"
 	"k8s.io/helm/pkg/chartutil"
-	"k8s.io/helm/pkg/getter"
 	helm_env "k8s.io/helm/pkg/helm/environment"
+	"k8s.io/helm/pkg/proto/hapi/chart"
 	"k8s.io/helm/pkg/repo"
 )
 
@@ -44,80 +44,280 @@ const (
 )
 
 // ListOutdatedDependencies returns a list of outdated dependencies of the given chart.
-func ListOutdatedDependencies(chartPath string, helmSettings *helm_env.EnvSettings, dependencyFilter *Filter) ([]*Result, error) {
-	chartDeps, err := loadDependencies(chartPath, dependencyFilter)
-	if err != nil {
-		if err == chartutil.ErrRequirementsNotFound {
-			fmt.Printf("Chart %v has no requirements.\n", chartPath)
-			return nil, nil
-		}
-		return nil, err
-	}
+func ListOutdatedDependencies(chartPath string, helmSettings *helm_env.EnvSettings, dependencyFilter *Filter, repoOpts *RepoUpdateOptions) ([]*Result, error) {
+	return ListOutdatedDependenciesOfCharts([]string{chartPath}, helmSettings, dependencyFilter, repoOpts)
+}
 
-	if err = parallelRepoUpdate(chartDeps, helmSettings); err != nil {
+// ListOutdatedDependenciesOfCharts returns a list of outdated dependencies of all given charts.
+// The index of each repository is only downloaded once, no matter how many charts depend on it.
+// A LookupError is returned along with the results if some dependencies could not be looked up.
+func ListOutdatedDependenciesOfCharts(chartPaths []string, helmSettings *helm_env.EnvSettings, dependencyFilter *Filter, repoOpts *RepoUpdateOptions) ([]*Result, error) {
+	res, err := ListDependenciesOfCharts(chartPaths, helmSettings, dependencyFilter, repoOpts)
+	if err != nil && !IsLookupError(err) {
 		return nil, err
 	}
+	return FilterOutdated(res), err
+}
+
+// chartDependencies are the dependencies of a chart and where they are declared.
+type chartDependencies struct {
+	reqs   *chartutil.Requirements
+	policy *Policy
+
+	// file is the absolute path of the file declaring the dependencies.
+	file  string
+	lines map[dependencyKey]int
+
+	// transitive are the dependencies of the subcharts at every level.
+	transitive []*transitiveDependency
+}
+
+// ListDependenciesOfCharts returns a list of all dependencies of the given charts along with their latest version.
+// The index of each repository is only downloaded once, no matter how many charts depend on it.
+// The indices are downloaded as given by the options or, if nil, the defaults.
+// A LookupError is returned along with the results if some dependencies could not be looked up or some indices could not be downloaded.
+func ListDependenciesOfCharts(chartPaths []string, helmSettings *helm_env.EnvSettings, dependencyFilter *Filter, repoOpts *RepoUpdateOptions) ([]*Result, error) {
+	return listDependenciesOfCharts(chartPaths, helmSettings, dependencyFilter, repoOpts, false)
+}
+
+// ListTransitiveDependenciesOfCharts is like ListDependenciesOfCharts, but also returns the dependencies of the subcharts
+// in the charts/ folder of the given charts at every level. Their results have the Path set.
+func ListTransitiveDependenciesOfCharts(chartPaths []string, helmSettings *helm_env.EnvSettings, dependencyFilter *Filter, repoOpts *RepoUpdateOptions) ([]*Result, error) {
+	return listDependenciesOfCharts(chartPaths, helmSettings, dependencyFilter, repoOpts, true)
+}
 
-	var res []*Result
-	for _, dep := range chartDeps.Dependencies {
-		depVersion, err := semver.NewVersion(dep.Version)
+func listDependenciesOfCharts(chartPaths []string, helmSettings *helm_env.EnvSettings, dependencyFilter *Filter, repoOpts *RepoUpdateOptions, isTransitive bool) ([]*Result, error) {
+	var (
+		allDeps   []*chartutil.Dependency
+		chartDeps = make(map[string]*chartDependencies, len(chartPaths))
+	)
+	for _, chartPath := range chartPaths {
+		reqs, reqsFileName, err := loadDependencies(chartPath, dependencyFilter)
 		if err != nil {
-			fmt.Printf("Error creating semVersion for dependency %s: %s", dep.Name, err.Error())
-			continue
+			if err == chartutil.ErrRequirementsNotFound {
+				fmt.Fprintf(os.Stderr, "Chart %v has no requirements.\n", chartPath)
+				continue
+			}
+			return nil, errors.Wrapf(err, "error loading dependencies of chart %s", chartPath)
+		}
+
+		policy, err := LoadPolicy(chartPath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error loading policy of chart %s", chartPath)
 		}
 
-		latestVersion, err := findLatestVersionOfDependency(dep, helmSettings)
+		// Ignored and snoozed dependencies are not even looked up.
+		reqs.Dependencies = policy.FilterDependencies(reqs.Dependencies, time.Now())
+
+		file := filepath.Join(chartPath, reqsFileName)
+		lines, err := findDependencyLines(file)
 		if err != nil {
-			fmt.Printf("Error getting latest version of %s: %s\n", dep.Name, err.Error())
+			fmt.Fprintf(os.Stderr, "Error finding dependencies in %s: %s\n", file, err.Error())
+		}
+
+		cd := &chartDependencies{reqs: reqs, policy: policy, file: file, lines: lines}
+		allDeps = append(allDeps, reqs.Dependencies...)
+
+		if isTransitive {
+			if cd.transitive, err = loadTransitiveDependencies(chartPath, dependencyFilter); err != nil {
+				return nil, errors.Wrapf(err, "error loading subcharts of chart %s", chartPath)
+			}
+			for _, td := range cd.transitive {
+				allDeps = append(allDeps, td.dep)
+			}
+		}
+		chartDeps[chartPath] = cd
+	}
+
+	repos, err := loadRepositories(helmSettings)
+	if err != nil {
+		return nil, err
+	}
+
+	// The latest versions of the replacements of deprecated dependencies are looked up as well.
+	allDeps = append(allDeps, repos.replacementDependencies(allDeps)...)
+	indexErrs := parallelRepoUpdate(allDeps, repos, helmSettings, repoOpts)
+
+	var (
+		res       []*Result
+		lookupErr = &LookupError{IndexErrors: sortedIndexErrors(indexErrs)}
+	)
+	for _, chartPath := range chartPaths {
+		cd, ok := chartDeps[chartPath]
+		if !ok {
 			continue
 		}
 
-		if depVersion.LessThan(latestVersion) {
-			currentVersion, err := semver.NewVersion(dep.Version)
+		for _, dep := range cd.reqs.Dependencies {
+			r, err := evaluateDependency(dep, cd.policy, repos, indexErrs)
 			if err != nil {
+				lookupErr.Errors = append(lookupErr.Errors, &DependencyError{ChartPath: chartPath, Dependency: dep, Err: err})
 				continue
 			}
 
-			res = append(res, &Result{
-				Dependency:     dep,
-				CurrentVersion: currentVersion,
-				LatestVersion:  latestVersion,
-			})
+			r.ChartPath = chartPath
+			r.File = cd.file
+			r.Line = cd.lines[dependencyKey{name: dep.Name, alias: dep.Alias}]
+			res = append(res, r)
 		}
+
+		// The policy of the chart only applies to its own dependencies.
+		for _, td := range cd.transitive {
+			r, err := evaluateDependency(td.dep, &Policy{}, repos, indexErrs)
+			if err != nil {
+				lookupErr.Errors = append(lookupErr.Errors, &DependencyError{ChartPath: chartPath, Dependency: td.dep, Err: err})
+				continue
+			}
+
+			// Transitive dependencies are vendored in the charts/ folder of the chart.
+			r.ChartPath = chartPath
+			r.File = filepath.Join(chartPath, chartsDirName)
+			r.Path = td.path
+			res = append(res, r)
+		}
+	}
+
+	res = sortResultsAlphabetically(res)
+	if len(lookupErr.Errors) > 0 || len(lookupErr.IndexErrors) > 0 {
+		return res, lookupErr
+	}
+	return res, nil
+}
+
+// evaluateDependency looks up the current and the latest version of the dependency allowed by the policy and whether it is deprecated.
+func evaluateDependency(dep *chartutil.Dependency, policy *Policy, repos *repositories, indexErrs map[string]*IndexError) (*Result, error) {
+	var indexErr *IndexError
+	if e, err := repos.entry(dep.Repository); err == nil {
+		indexErr = indexErrs[e.Name]
+	}
+
+	releases, err := findReleasesOfDependency(dep, repos)
+	if err != nil {
+		if indexErr != nil {
+			err = errors.Wrap(err, "no cached index")
+		}
+		fmt.Fprintf(os.Stderr, "Error getting versions of %s: %s\n", dep.Name, err.Error())
+		return nil, err
 	}
 
-	return sortResultsAlphabetically(res), nil
+	versions := releases.versions
+	depVersion, constraint, err := resolveVersion(dep.Version, versions)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error resolving version %s of dependency %s: %s\n", dep.Version, dep.Name, err.Error())
+		return nil, err
+	}
+
+	// Only consider the versions allowed by the policy of the chart.
+	latestVersion := depVersion
+	if allowed := policy.FilterVersions(dep, depVersion, versions); len(allowed) > 0 && depVersion.LessThan(allowed[len(allowed)-1]) {
+		latestVersion = allowed[len(allowed)-1]
+	}
+
+	r := &Result{
+		Dependency:     dep,
+		CurrentVersion: depVersion,
+		LatestVersion:  latestVersion,
+		Constraint:     constraint,
+		IndexError:     indexErr,
+		Deprecated:     releases.isDeprecated(),
+	}
+	r.CurrentAppVersion, r.LatestAppVersion = releases.appVersion(depVersion), releases.appVersion(latestVersion)
+	r.CurrentReleaseDate, r.LatestReleaseDate = releases.created(depVersion), releases.created(latestVersion)
+	r.VersionsBehind = releases.countBetween(depVersion, latestVersion)
+	if e, err := repos.lookup(dep.Repository); err == nil && e != nil {
+		r.RepositoryName, r.RepositoryURL = e.Name, e.URL
+	}
+
+	// A configured replacement marks the dependency as deprecated, even if its repository does not.
+	if cr := repos.replacementFor(dep); cr != nil {
+		r.Deprecated = true
+		r.Replacement = repos.lookupReplacement(dep, cr)
+	}
+	return r, nil
+}
+
+// UpdateChart increments the version of the chart by the given IncType and updates its dependencies.
+// Use IncTypes.None to keep the chart version. The repository indices are handled as given by the options or, if nil, the defaults.
+// The update is atomic: If any step fails, the chart is restored to the state before the update.
+func UpdateChart(chartPath string, reqsToUpdate []*Result, incType IncType, helmSettings *helm_env.EnvSettings, repoOpts *RepoUpdateOptions) error {
+	snapshot, err := newChartSnapshot(chartPath)
+	if err != nil {
+		return err
+	}
+
+	if err := updateChart(chartPath, reqsToUpdate, incType, helmSettings, repoOpts); err != nil {
+		// A partially restored chart is missing files, which are only left in the backup, so it is kept.
+		if restoreErr := snapshot.restore(); restoreErr != nil {
+			return errors.Wrapf(err, "error restoring chart %s after failed update, the previous state is kept in %s: %s", chartPath, snapshot.backupPath, restoreErr.Error())
+		}
+		snapshot.discard()
+		return errors.Wrapf(err, "error updating chart %s, restored previous state", chartPath)
+	}
+
+	snapshot.discard()
+	return nil
+}
+
+func updateChart(chartPath string, reqsToUpdate []*Result, incType IncType, helmSettings *helm_env.EnvSettings, repoOpts *RepoUpdateOptions) error {
+	if incType != IncTypes.None {
+		if err := IncrementChartVersion(chartPath, incType); err != nil {
+			return err
+		}
+	}
+	return UpdateDependencies(chartPath, reqsToUpdate, helmSettings, repoOpts)
 }
 
 // UpdateDependencies updates the dependencies of the given chart.
-func UpdateDependencies(chartPath string, reqsToUpdate []*Result, indent int, helmSettings *helm_env.EnvSettings) error {
+// Only the versions of the updated dependencies are changed in the requirements.yaml or Chart.yaml.
+// In offline mode the repository indices are not downloaded again, if the lock file cannot be updated using the cached ones.
+func UpdateDependencies(chartPath string, reqsToUpdate []*Result, helmSettings *helm_env.EnvSettings, repoOpts *RepoUpdateOptions) error {
+	if repoOpts == nil {
+		repoOpts = DefaultRepoUpdateOptions()
+	}
+
 	c, err := chartutil.Load(chartPath)
 	if err != nil {
 		return err
 	}
 
-	reqs, err := chartutil.LoadRequirements(c)
+	depVersions, err := getDependencyVersions(reqsToUpdate)
 	if err != nil {
 		return err
 	}
 
-	for _, newDep := range reqsToUpdate {
-		for _, oldDep := range reqs.Dependencies {
-			if newDep.Name == oldDep.Name && newDep.Repository == newDep.Repository {
-				oldDep.Version = newDep.LatestVersion.String()
-			}
+	if !isAPIVersionV2(c) {
+		reqs, err := loadRequirements(chartPath, c)
+		if err != nil {
+			return err
+		}
+		if err := checkOCIDependencies(c, reqs); err != nil {
+			return err
 		}
 	}
 
-	reqs = sortRequirementsAlphabetically(reqs)
-	if err := writeRequirements(chartPath, reqs, indent); err != nil {
+	if err := writeDependencyVersions(chartPath, requirementsFileName(c), depVersions); err != nil {
 		return err
 	}
 
-	return syncRequirementsLock(chartPath, helmSettings)
+	if !isAPIVersionV2(c) {
+		repos, err := loadRepositories(helmSettings)
+		if err != nil {
+			return err
+		}
+		return syncRequirementsLock(chartPath, repos, helmSettings, repoOpts.Offline)
+	}
+
+	// Reload the updated dependencies from the Chart.yaml.
+	reqs, err := loadRequirements(chartPath, c)
+	if err != nil {
+		return err
+	}
+	return syncChartLock(chartPath, reqs, helmSettings)
 }
 
-func syncRequirementsLock(chartPath string, helmSettings *helm_env.EnvSettings) error {
+// syncRequirementsLock updates the requirements.lock and the charts/ folder of an apiVersion v1 chart.
+// Charts and indices are downloaded using the credentials of the repositories. Indices are not downloaded in offline mode.
+func syncRequirementsLock(chartPath string, repos *repositories, helmSettings *helm_env.EnvSettings, isOffline bool) error {
 	var out bytes.Buffer
 
 	debug := false
@@ -133,13 +333,17 @@ func syncRequirementsLock(chartPath string, helmSettings *helm_env.EnvSettings)
 		Debug:      debug,
 		Keyring:    os.ExpandEnv("$HOME/.gnupg/pubring.gpg"),
 		SkipUpdate: true,
-		Getters:    getter.All(*helmSettings),
+		Getters:    repos.getters(helmSettings),
 	}
 
 	// Try to update the dependencies assuming the repositories were refreshed already.
 	// If not, update the repositories and try again.
 	if err := dm.Update(); err != nil {
-		fmt.Printf("error updating helm dependencies: %s\n", out.String())
+		fmt.Fprintf(os.Stderr, "error updating helm dependencies: %s\n", out.String())
+
+		if isOffline {
+			return errors.Wrap(err, "error updating helm dependencies using the cached repository indices in offline mode")
+		}
 
 		if err := dm.UpdateRepositories(); err != nil {
 			return errors.Wrap(err, "error during helm repository update")
@@ -163,18 +367,8 @@ func IncrementChartVersion(chartPath string, incType IncType) error {
 		return err
 	}
 
-	var newVersion semver.Version
-	switch incType {
-	case IncTypes.Major:
-		newVersion = chartVersion.IncMajor()
-	case IncTypes.Minor:
-		newVersion = chartVersion.IncMinor()
-	default:
-		newVersion = chartVersion.IncPatch()
-	}
-
-	c.Metadata.Version = newVersion.String()
-	return writeChartMetadata(chartPath, c.Metadata)
+	newVersion := incrementVersion(chartVersion, incType)
+	return writeChartVersion(chartPath, newVersion.String())
 }
 
 // GetChartName returns the name of the chart in the given path or an error.
@@ -187,93 +381,147 @@ func GetChartName(chartPath string) (string, error) {
 	return c.GetMetadata().GetName(), nil
 }
 
-// loadDependencies loads the dependencies of the given chart.
-func loadDependencies(chartPath string, f *Filter) (*chartutil.Requirements, error) {
+// loadDependencies loads the dependencies of the given chart and returns them along with the name of the file declaring them.
+func loadDependencies(chartPath string, f *Filter) (*chartutil.Requirements, string, error) {
 	c, err := chartutil.Load(chartPath)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	reqs, err := chartutil.LoadRequirements(c)
+	reqs, err := loadRequirements(chartPath, c)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	var deps []*chartutil.Dependency
 	for _, d := range reqs.Dependencies {
 		if strings.Contains(d.Repository, filePrefix) {
-			d.Repository = fmt.Sprintf("%s%s", filePrefix, filepath.Join(chartPath, strings.TrimPrefix(d.Repository, filePrefix)))
+			d.Repository = filePrefix + localChartPath(chartPath, d.Repository)
 		}
 		deps = append(deps, d)
 	}
 
 	reqs.Dependencies = f.FilterDependencies(deps)
-	return reqs, nil
+	return reqs, requirementsFileName(c), nil
 }
 
-// findLatestVersionOfDependency returns the latest version of the given dependency in the repository.
-func findLatestVersionOfDependency(dep *chartutil.Dependency, helmSettings *helm_env.EnvSettings) (*semver.Version, error) {
-	// Handle local dependencies.
-	if strings.Contains(dep.Repository, filePrefix) {
-		c, err := chartutil.Load(strings.TrimPrefix(dep.Repository, filePrefix))
-		if err != nil {
-			return nil, err
-		}
-		return semver.NewVersion(c.Metadata.Version)
-	}
-
-	// Read the index file for the repository to get chart information and return chart URL
-	repoIndex, err := repo.LoadIndexFile(helmSettings.Home.CacheIndex(normalizeRepoName(dep.Repository)))
+// findVersionsOfDependency returns all released versions of the given dependency in the repository in ascending order.
+// Tags, which are not a semantic version, and pre-releases are ignored.
+// Repositories referenced by alias are looked up in the given repositories.
+func findVersionsOfDependency(dep *chartutil.Dependency, repos *repositories) (semver.Collection, error) {
+	releases, err := findReleasesOfDependency(dep, repos)
 	if err != nil {
 		return nil, err
 	}
+	return releases.versions, nil
+}
 
-	// With no version given the highest one is returned.
-	cv, err := repoIndex.Get(dep.Name, "")
-	if err != nil {
-		return nil, err
-	}
+// chartReleases are the released versions of a chart in ascending order along with their entries in the repository index.
+type chartReleases struct {
+	versions semver.Collection
 
-	return semver.NewVersion(cv.Version)
+	// entries by version. Charts in OCI registries have none.
+	entries map[string]*repo.ChartVersion
 }
 
-func sortRequirementsAlphabetically(reqs *chartutil.Requirements) *chartutil.Requirements {
-	sort.Slice(reqs.Dependencies, func(i, j int) bool {
-		return reqs.Dependencies[i].Name < reqs.Dependencies[j].Name
-	})
-	return reqs
+// entry returns the entry of the given version in the repository index or nil.
+func (c *chartReleases) entry(v *semver.Version) *repo.ChartVersion {
+	return c.entries[v.String()]
 }
 
-func parallelRepoUpdate(chartDeps *chartutil.Requirements, helmSettings *helm_env.EnvSettings) error {
-	var repos []string
-	for _, dep := range chartDeps.Dependencies {
-		if !stringSliceContains(repos, dep.Repository) && !strings.Contains(dep.Repository, filePrefix) {
-			repos = append(repos, dep.Repository)
+// appVersion returns the appVersion of the given version or an empty string if unknown.
+func (c *chartReleases) appVersion(v *semver.Version) string {
+	e := c.entry(v)
+	if e == nil {
+		return ""
+	}
+	return e.GetAppVersion()
+}
+
+// created returns the release date of the given version or the zero time if unknown.
+func (c *chartReleases) created(v *semver.Version) time.Time {
+	e := c.entry(v)
+	if e == nil {
+		return time.Time{}
+	}
+	return e.Created
+}
+
+// countBetween returns the number of versions newer than the given one up to and including the latest one.
+func (c *chartReleases) countBetween(current, latest *semver.Version) int {
+	n := 0
+	for _, v := range c.versions {
+		if current.LessThan(v) && !latest.LessThan(v) {
+			n++
 		}
 	}
+	return n
+}
+
+// isDeprecated checks whether the newest release of the chart is marked deprecated.
+func (c *chartReleases) isDeprecated() bool {
+	if len(c.versions) == 0 {
+		return false
+	}
+	e := c.entry(c.versions[len(c.versions)-1])
+	return e != nil && e.GetDeprecated()
+}
 
-	var wg sync.WaitGroup
-	for _, c := range repos {
-		tmpRepo := &repo.Entry{
-			Name: normalizeRepoName(c),
-			URL:  c,
+// findReleasesOfDependency is like findVersionsOfDependency, but also returns the entries of the versions in the repository index.
+// The entry of a local dependency is taken from its Chart.yaml.
+func findReleasesOfDependency(dep *chartutil.Dependency, repos *repositories) (*chartReleases, error) {
+	var entries repo.ChartVersions
+	switch {
+	case strings.Contains(dep.Repository, filePrefix):
+		// Handle local dependencies.
+		c, err := chartutil.Load(strings.TrimPrefix(dep.Repository, filePrefix))
+		if err != nil {
+			return nil, err
 		}
 
-		r, err := repo.NewChartRepository(tmpRepo, getter.All(*helmSettings))
+		if _, err := semver.NewVersion(c.Metadata.Version); err != nil {
+			return nil, err
+		}
+		entries = repo.ChartVersions{{Metadata: c.Metadata}}
+
+	case isOCIRepository(dep.Repository):
+		// OCI registries have no index. The versions are the tags of the chart.
+		tags, err := defaultOCIClient.listTags(dep.Repository, dep.Name, repos.authFor(dep.Repository))
 		if err != nil {
-			return err
+			return nil, err
+		}
+		for _, tag := range tags {
+			entries = append(entries, &repo.ChartVersion{Metadata: &chart.Metadata{Name: dep.Name, Version: tag}})
 		}
 
-		wg.Add(1)
-		go func(r *repo.ChartRepository) {
-			if err := r.DownloadIndexFile(helmSettings.Home.CacheIndex(tmpRepo.Name)); err != nil {
-				fmt.Printf("unable to get an update from the %q chart repository (%s):\n\t%s\n", r.Config.Name, r.Config.URL, err)
-			} else {
-				fmt.Printf("successfully got an update from the %q chart repository\n", r.Config.URL)
-			}
-			wg.Done()
-		}(r)
+	default:
+		e, err := repos.entry(dep.Repository)
+		if err != nil {
+			return nil, err
+		}
+
+		// Read the index file for the repository to get chart information.
+		repoIndex, err := repo.LoadIndexFile(repos.cacheIndexFile(e))
+		if err != nil {
+			return nil, err
+		}
+		entries = repoIndex.Entries[dep.Name]
 	}
-	wg.Wait()
-	return nil
+
+	releases := &chartReleases{entries: make(map[string]*repo.ChartVersion, len(entries))}
+	for _, cv := range entries {
+		v, err := semver.NewVersion(cv.GetVersion())
+		if err != nil || v.Prerelease() != "" {
+			continue
+		}
+		releases.versions = append(releases.versions, v)
+		releases.entries[v.String()] = cv
+	}
+
+	if len(releases.versions) == 0 {
+		return nil, errors.Errorf("no version of chart %s found in repository %s", dep.Name, dep.Repository)
+	}
+
+	sort.Sort(releases.versions)
+	return releases, nil
 }
@@ -22,48 +22,85 @@ package helm
 import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"k8s.io/helm/pkg/chartutil"
+	"io/ioutil"
 	"os"
 	"path"
 	"testing"
+
+	"k8s.io/helm/pkg/chartutil"
 )
 
-func newRequirements() *chartutil.Requirements {
-	return &chartutil.Requirements{
-		Dependencies: []*chartutil.Dependency{
-			{
-				Name:       "testdependency",
-				Version:    "v0.0.1",
-				Repository: "https://repo.evil.corp",
-			},
-			{
-				Name:       "testdepdendency1",
-				Version:    "v0.0.2",
-				Repository: "https://repo.evil.corp",
-			},
-		},
-	}
+const requirementsFile = `# Dependencies of the chart.
+dependencies:
+    # Keep this one first.
+    - name: testdependency
+      repository: https://repo.evil.corp
+      version: v0.0.1 # pinned
+    - alias: other
+      name: testdependency
+      repository: https://repo.evil.corp
+      version: "v0.0.1"
+    - name: atestdependency
+      repository: https://repo.evil.corp
+      version: '~0.1.0'
+`
+
+const expectedRequirementsFile = `# Dependencies of the chart.
+dependencies:
+    # Keep this one first.
+    - name: testdependency
+      repository: https://repo.evil.corp
+      version: v0.0.2 # pinned
+    - alias: other
+      name: testdependency
+      repository: https://repo.evil.corp
+      version: "v0.0.1"
+    - name: atestdependency
+      repository: https://repo.evil.corp
+      version: '~0.2.0'
+`
+
+func TestWriteDependencyVersions(t *testing.T) {
+	chartPath, err := ioutil.TempDir("", "chart")
+	require.NoError(t, err, "there must be no error creating the chart directory")
+	defer os.RemoveAll(chartPath)
+	require.NoError(t, ioutil.WriteFile(path.Join(chartPath, requirementsName), []byte(requirementsFile), 0644), "there must be no error writing the requirements.yaml")
+
+	err = writeDependencyVersions(chartPath, requirementsName, []dependencyVersion{
+		{name: "testdependency", version: "v0.0.2"},
+		{name: "atestdependency", version: "~0.2.0"},
+	})
+	require.NoError(t, err, "there should be no error writing the chart requirements")
+
+	data, err := ioutil.ReadFile(path.Join(chartPath, requirementsName))
+	require.NoError(t, err, "there must be no error reading the requirements.yaml")
+	assert.Equal(t, expectedRequirementsFile, string(data), "only the updated versions should be changed")
+
+	err = writeDependencyVersions(chartPath, requirementsName, []dependencyVersion{{name: "unknown", version: "v0.0.2"}})
+	assert.Error(t, err, "there should be an error updating an unknown dependency")
 }
 
-func ensureEmptyFileExists(pathParts ...string) error {
-	filepath := path.Join(pathParts...)
-	if f, err := os.Create(filepath); err != nil {
-		if os.IsExist(err) {
-			return f.Truncate(0)
-		}
-		return err
-	}
-	return nil
+func TestSetDependencyVersionsQuotesConstraints(t *testing.T) {
+	data, err := setDependencyVersions([]byte("dependencies:\n- name: foo\n  version: ^1.0.0\n"), []dependencyVersion{{name: "foo", version: ">=2.0.0 <3.0.0"}})
+	require.NoError(t, err, "there should be no error setting the version")
+	assert.Equal(t, "dependencies:\n- name: foo\n  version: \">=2.0.0 <3.0.0\"\n", string(data))
 }
 
-func TestWriteRequirements(t *testing.T) {
-	dir, err := os.Getwd()
-	require.NoError(t, err, "there must be no error getting the current path")
-	chartPath := path.Join(dir, "fixtures")
-	require.NoError(t, ensureEmptyFileExists(chartPath, requirementsName), "there must be no error creating the requirements.yaml")
+func TestSetDependencyVersionsReplacementKeepsValues(t *testing.T) {
+	depVersions := []dependencyVersion{{name: "prometheus-operator", version: "9.0.0", newName: "kube-prometheus-stack"}}
 
-	err = writeRequirements(chartPath, newRequirements(), 4)
-	assert.NoError(t, err, "there should be no error writing the chart requirements")
+	data, err := setDependencyVersions([]byte("dependencies:\n  - name: prometheus-operator # deprecated\n    version: 8.0.0\n"), depVersions)
+	require.NoError(t, err, "there should be no error replacing the dependency")
+	assert.Equal(t, "dependencies:\n  - name: kube-prometheus-stack # deprecated\n    alias: prometheus-operator\n    version: 9.0.0\n", string(data), "the old name should be kept as alias")
+
+	data, err = setDependencyVersions([]byte("dependencies:\n  - {name: prometheus-operator, version: 8.0.0}\n"), depVersions)
+	require.NoError(t, err, "there should be no error replacing the dependency in flow style")
+	assert.Equal(t, "dependencies:\n  - {name: kube-prometheus-stack, alias: prometheus-operator, version: 9.0.0}\n", string(data))
+
+	depVersions[0].alias = "prometheus"
+	data, err = setDependencyVersions([]byte("dependencies:\n  - name: prometheus-operator\n    alias: prometheus\n    version: 8.0.0\n"), depVersions)
+	require.NoError(t, err)
+	assert.Equal(t, "dependencies:\n  - name: kube-prometheus-stack\n    alias: prometheus\n    version: 9.0.0\n", string(data), "an existing alias should be kept")
 }
 
 func TestIncrementChartVersion(t *testing.T) {
@@ -74,3 +111,75 @@ func TestIncrementChartVersion(t *testing.T) {
 	err = IncrementChartVersion(chartPath, IncTypes.Patch)
 	assert.NoError(t, err, "there should be no error incrementing the chart version and writing the new Chart.yaml")
 }
+
+func TestFindDependencyLines(t *testing.T) {
+	chartPath, err := ioutil.TempDir("", "chart")
+	require.NoError(t, err, "there must be no error creating the chart directory")
+	defer os.RemoveAll(chartPath)
+	require.NoError(t, ioutil.WriteFile(path.Join(chartPath, requirementsName), []byte(requirementsFile), 0644), "there must be no error writing the requirements.yaml")
+
+	lines, err := findDependencyLines(path.Join(chartPath, requirementsName))
+	require.NoError(t, err, "there should be no error finding the dependencies")
+	assert.Equal(t, map[dependencyKey]int{
+		{name: "testdependency"}:                 4,
+		{name: "testdependency", alias: "other"}: 7,
+		{name: "atestdependency"}:                11,
+	}, lines)
+}
+
+const appVersionIndexFile = `apiVersion: v1
+entries:
+  prometheus-operator:
+  - name: prometheus-operator
+    version: 8.0.0
+  redis:
+  - name: redis
+    version: 1.1.0
+    appVersion: 5.0.7
+  - name: redis
+    version: 1.0.0
+    appVersion: 5.0.5
+`
+
+func TestEvaluateDependencyAppVersion(t *testing.T) {
+	helmSettings, cleanup := newHelmSettings(t)
+	defer cleanup()
+	require.NoError(t, os.MkdirAll(helmSettings.Home.Cache(), 0755))
+
+	repos, err := loadRepositories(helmSettings)
+	require.NoError(t, err)
+	e, err := repos.entry("@stable")
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(repos.cacheIndexFile(e), []byte(appVersionIndexFile), 0644))
+
+	r, err := evaluateDependency(&chartutil.Dependency{Name: "redis", Repository: "@stable", Version: "1.0.0"}, &Policy{}, repos, nil)
+	require.NoError(t, err, "there should be no error evaluating the dependency")
+	assert.Equal(t, "5.0.5", r.CurrentAppVersion)
+	assert.Equal(t, "5.0.7", r.LatestAppVersion)
+	assert.True(t, r.IsAppVersionChanged())
+
+	r, err = evaluateDependency(&chartutil.Dependency{Name: "prometheus-operator", Repository: "@stable", Version: "8.0.0"}, &Policy{}, repos, nil)
+	require.NoError(t, err)
+	assert.Empty(t, r.LatestAppVersion, "the appVersion should be empty if unknown")
+	assert.False(t, r.IsAppVersionChanged())
+}
+
+func TestUpdateDependenciesOffline(t *testing.T) {
+	var downloads int32
+	srv := newTestChartRepository(t, &downloads)
+	defer srv.Close()
+
+	helmSettings, cleanup := newHelmSettings(t)
+	defer cleanup()
+
+	chartPath, err := ioutil.TempDir("", "chart")
+	require.NoError(t, err, "there must be no error creating the chart directory")
+	defer os.RemoveAll(chartPath)
+	require.NoError(t, ioutil.WriteFile(path.Join(chartPath, chartMetadataName), []byte("apiVersion: v1\nname: umbrella\nversion: 1.0.0\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(path.Join(chartPath, requirementsName), []byte("dependencies:\n  - name: nginx\n    repository: "+srv.URL+"\n    version: 1.0.0\n"), 0644))
+
+	err = UpdateDependencies(chartPath, nil, helmSettings, &RepoUpdateOptions{Offline: true})
+	require.Error(t, err, "the dependencies cannot be updated without a cached index")
+	assert.Contains(t, err.Error(), "offline mode")
+	assert.Equal(t, int32(0), downloads, "no index should be downloaded in offline mode")
+}
@@ -0,0 +1,177 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package helm
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/pkg/errors"
+	"k8s.io/helm/pkg/chartutil"
+)
+
+// ChartReplacement maps a deprecated chart to its replacement.
+// It is configured in the plugin configuration. Dependencies matching it are considered deprecated.
+type ChartReplacement struct {
+	// Repository and Name of the deprecated chart. At least one of them is required.
+	// All charts of the repository are matched if the name is omitted.
+	Repository string `json:"repository,omitempty"`
+	Name       string `json:"name,omitempty"`
+
+	// NewRepository and NewName of the replacement. The repository or name of the deprecated chart is kept if omitted.
+	NewRepository string `json:"newRepository,omitempty"`
+	NewName       string `json:"newName,omitempty"`
+}
+
+// Replacement is the suggested replacement of a deprecated dependency.
+type Replacement struct {
+	Name,
+	Repository string
+
+	// LatestVersion of the replacement. Nil if it could not be looked up.
+	LatestVersion *semver.Version
+}
+
+// String returns the replacement like "kube-prometheus-stack@12.0.0 (https://prometheus-community.github.io/helm-charts)".
+func (r *Replacement) String() string {
+	if r.LatestVersion == nil {
+		return fmt.Sprintf("%s (%s)", r.Name, r.Repository)
+	}
+	return fmt.Sprintf("%s@%s (%s)", r.Name, r.LatestVersion.String(), r.Repository)
+}
+
+func (cr *ChartReplacement) validate() error {
+	if cr.Repository == "" && cr.Name == "" {
+		return errors.New("repository or name is required")
+	}
+	if cr.NewRepository == "" && cr.NewName == "" {
+		return errors.New("newRepository or newName is required")
+	}
+	return nil
+}
+
+// replace returns the dependency pointing to the replacement.
+func (cr *ChartReplacement) replace(dep *chartutil.Dependency) *chartutil.Dependency {
+	d := *dep
+	if cr.NewRepository != "" {
+		d.Repository = cr.NewRepository
+	}
+	if cr.NewName != "" {
+		d.Name = cr.NewName
+	}
+	return &d
+}
+
+// replacementFor returns the replacement configured for the given dependency or nil.
+func (r *repositories) replacementFor(dep *chartutil.Dependency) *ChartReplacement {
+	for _, cr := range r.replacements {
+		if cr.Name != "" && cr.Name != dep.Name {
+			continue
+		}
+		if cr.Repository != "" && r.resolveURL(cr.Repository) != r.resolveURL(dep.Repository) {
+			continue
+		}
+		return cr
+	}
+	return nil
+}
+
+// replacementDependencies returns the replacements of the given dependencies.
+func (r *repositories) replacementDependencies(deps []*chartutil.Dependency) []*chartutil.Dependency {
+	var res []*chartutil.Dependency
+	for _, dep := range deps {
+		if cr := r.replacementFor(dep); cr != nil {
+			res = append(res, cr.replace(dep))
+		}
+	}
+	return res
+}
+
+// lookupReplacement returns the replacement of the dependency along with its latest version.
+func (r *repositories) lookupReplacement(dep *chartutil.Dependency, cr *ChartReplacement) *Replacement {
+	newDep := cr.replace(dep)
+	res := &Replacement{Name: newDep.Name, Repository: newDep.Repository}
+
+	versions, err := findVersionsOfDependency(newDep, r)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error getting versions of %s, the replacement of %s: %s\n", newDep.Name, dep.Name, err.Error())
+		return res
+	}
+
+	res.LatestVersion = versions[len(versions)-1]
+	return res
+}
+
+// resolveURL returns the URL of the repository, which might be referenced by its name, without trailing slash.
+func (r *repositories) resolveURL(repository string) string {
+	if e, err := r.lookup(repository); err == nil && e != nil {
+		repository = e.URL
+	}
+	return strings.TrimSuffix(repository, "/")
+}
+
+// deprecationMessage describes the deprecation of the dependency and its replacement if any.
+func deprecationMessage(r *Result) string {
+	if r.Replacement == nil {
+		return fmt.Sprintf("Dependency %s is deprecated", r.displayName())
+	}
+	return fmt.Sprintf("Dependency %s is deprecated, replace it with %s", r.displayName(), r.Replacement.String())
+}
+
+// FilterDeprecated returns only the results of deprecated dependencies.
+func FilterDeprecated(results []*Result) []*Result {
+	var deprecated []*Result
+	for _, r := range results {
+		if r.Deprecated {
+			deprecated = append(deprecated, r)
+		}
+	}
+	return deprecated
+}
+
+// FilterOutdatedOrDeprecated returns the results of dependencies, which are outdated or deprecated.
+func FilterOutdatedOrDeprecated(results []*Result) []*Result {
+	var res []*Result
+	for _, r := range results {
+		if r.IsOutdated() || r.Deprecated {
+			res = append(res, r)
+		}
+	}
+	return res
+}
+
+// FilterUpdates returns the results of the dependencies to update.
+// These are the outdated ones and, if replaceDeprecated is set, the deprecated ones with a replacement, which are marked to be replaced.
+func FilterUpdates(results []*Result, replaceDeprecated bool) []*Result {
+	var res []*Result
+	for _, r := range results {
+		if replaceDeprecated && r.Replacement != nil && r.Replacement.LatestVersion != nil {
+			r.Replace = true
+			res = append(res, r)
+			continue
+		}
+		if r.IsOutdated() {
+			res = append(res, r)
+		}
+	}
+	return res
+}
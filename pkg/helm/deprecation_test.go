@@ -0,0 +1,113 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package helm
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/helm/pkg/chartutil"
+)
+
+const (
+	stableIndexFile = `apiVersion: v1
+entries:
+  prometheus-operator:
+  - name: prometheus-operator
+    version: 8.5.1
+    deprecated: true
+  - name: prometheus-operator
+    version: 8.0.0
+  redis:
+  - name: redis
+    version: 1.0.0
+`
+
+	prometheusCommunityIndexFile = `apiVersion: v1
+entries:
+  kube-prometheus-stack:
+  - name: kube-prometheus-stack
+    version: 12.0.0
+  - name: kube-prometheus-stack
+    version: 11.1.0
+`
+
+	replacementsConfig = `replacements:
+  - repository: "@stable"
+    name: prometheus-operator
+    newRepository: https://prometheus-community.github.io/helm-charts
+    newName: kube-prometheus-stack
+`
+)
+
+func TestEvaluateDeprecatedDependency(t *testing.T) {
+	helmSettings, cleanup := newHelmSettings(t)
+	defer cleanup()
+	require.NoError(t, os.MkdirAll(helmSettings.Home.Cache(), 0755))
+	require.NoError(t, ioutil.WriteFile(helmSettings.Home.Path(pluginConfigName), []byte(replacementsConfig), 0644))
+
+	repos, err := loadRepositories(helmSettings)
+	require.NoError(t, err, "there should be no error loading the repositories and replacements")
+
+	for repository, index := range map[string]string{"@stable": stableIndexFile, "https://prometheus-community.github.io/helm-charts": prometheusCommunityIndexFile} {
+		e, err := repos.entry(repository)
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(repos.cacheIndexFile(e), []byte(index), 0644))
+	}
+
+	prometheusOperator := &chartutil.Dependency{Name: "prometheus-operator", Repository: "https://kubernetes-charts.storage.googleapis.com", Version: "8.5.1"}
+	r, err := evaluateDependency(prometheusOperator, &Policy{}, repos, nil)
+	require.NoError(t, err, "there should be no error evaluating the dependency")
+	assert.False(t, r.IsOutdated())
+	assert.True(t, r.Deprecated, "the dependency should be deprecated even though it is up to date")
+	require.NotNil(t, r.Replacement, "the replacement should be suggested")
+	assert.Equal(t, "kube-prometheus-stack@12.0.0 (https://prometheus-community.github.io/helm-charts)", r.Replacement.String())
+
+	redis := &chartutil.Dependency{Name: "redis", Repository: "@stable", Version: "1.0.0"}
+	r2, err := evaluateDependency(redis, &Policy{}, repos, nil)
+	require.NoError(t, err)
+	assert.False(t, r2.Deprecated)
+	assert.Nil(t, r2.Replacement)
+
+	results := []*Result{r, r2}
+	assert.Empty(t, FilterUpdates(results, false), "deprecated dependencies should only be replaced if requested")
+
+	updates := FilterUpdates(results, true)
+	require.Len(t, updates, 1)
+	assert.True(t, updates[0].Replace)
+
+	depVersions, err := getDependencyVersions(updates)
+	require.NoError(t, err)
+	data, err := setDependencyVersions([]byte("dependencies:\n  # The operator.\n  - name: prometheus-operator\n    repository: https://kubernetes-charts.storage.googleapis.com\n    version: 8.5.1\n"), depVersions)
+	require.NoError(t, err, "there should be no error replacing the dependency")
+	assert.Equal(t, "dependencies:\n  # The operator.\n  - name: kube-prometheus-stack\n    alias: prometheus-operator\n    repository: https://prometheus-community.github.io/helm-charts\n    version: 12.0.0\n", string(data), "the old name should be kept as alias")
+}
+
+func TestLoadPluginConfigInvalidReplacement(t *testing.T) {
+	helmSettings, cleanup := newHelmSettings(t)
+	defer cleanup()
+	require.NoError(t, ioutil.WriteFile(helmSettings.Home.Path(pluginConfigName), []byte("replacements:\n  - name: prometheus-operator\n"), 0644))
+
+	_, err := loadPluginConfig(helmSettings)
+	assert.Error(t, err, "a replacement without new repository or name should be an error")
+}
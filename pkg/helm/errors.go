@@ -0,0 +1,92 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package helm
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/helm/pkg/chartutil"
+)
+
+// DependencyError is an error looking up the versions of a dependency.
+type DependencyError struct {
+	ChartPath  string
+	Dependency *chartutil.Dependency
+	Err        error
+}
+
+func (e *DependencyError) Error() string {
+	return fmt.Sprintf("error looking up dependency %s of chart %s: %s", e.Dependency.Name, e.ChartPath, e.Err.Error())
+}
+
+// IndexError is an error downloading the index of a repository.
+type IndexError struct {
+	Repository string
+	URL        string
+	Err        error
+}
+
+func (e *IndexError) Error() string {
+	return fmt.Sprintf("error downloading index of repository %s (%s): %s", e.Repository, e.URL, e.Err.Error())
+}
+
+// statusError is an unexpected status of the response to a request.
+type statusError struct {
+	URL        string
+	Status     string
+	StatusCode int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("failed to fetch %s: %s", e.URL, e.Status)
+}
+
+// LookupError is returned along with the results if the versions of some dependencies could not be looked up
+// or the indices of some repositories could not be downloaded.
+// Dependencies, which could not be looked up, are missing in the results.
+// Dependencies of repositories, which could not be downloaded, were evaluated against the cached index if any.
+type LookupError struct {
+	Errors      []*DependencyError
+	IndexErrors []*IndexError
+}
+
+func (e *LookupError) Error() string {
+	var msgs []string
+	if len(e.Errors) > 0 {
+		msgs = append(msgs, fmt.Sprintf("%d dependencies could not be looked up:", len(e.Errors)))
+		for _, err := range e.Errors {
+			msgs = append(msgs, err.Error())
+		}
+	}
+	if len(e.IndexErrors) > 0 {
+		msgs = append(msgs, fmt.Sprintf("%d repository indices could not be downloaded:", len(e.IndexErrors)))
+		for _, err := range e.IndexErrors {
+			msgs = append(msgs, err.Error())
+		}
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// IsLookupError checks whether the given error is a LookupError.
+func IsLookupError(err error) bool {
+	_, ok := err.(*LookupError)
+	return ok
+}
@@ -0,0 +1,209 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package helm
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	graphPathSeparator = " > "
+	graphOutdatedColor = "red"
+)
+
+// Graph is the dependency graph of charts.
+type Graph struct {
+	Nodes []*GraphNode `json:"nodes"`
+	Edges []*GraphEdge `json:"edges"`
+}
+
+// GraphNode is a chart in the Graph.
+// Charts are identified by their path relative to the root path, remote dependencies by repository and name
+// and the subcharts of transitive dependencies by their path.
+type GraphNode struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Repository string `json:"repository,omitempty"`
+}
+
+// GraphEdge is a dependency in the Graph.
+type GraphEdge struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Alias string `json:"alias,omitempty"`
+
+	// Version is the version as declared by the chart, which might also be a constraint.
+	Version        string  `json:"version"`
+	CurrentVersion string  `json:"currentVersion"`
+	LatestVersion  string  `json:"latestVersion"`
+	Outdated       bool    `json:"outdated"`
+	UpdateType     IncType `json:"updateType,omitempty"`
+}
+
+// NewGraph returns the dependency graph of the given results.
+// Local dependencies point to the node of the chart if it is part of the results as well.
+func NewGraph(rootPath string, results []*Result) *Graph {
+	g := &Graph{Nodes: make([]*GraphNode, 0), Edges: make([]*GraphEdge, 0)}
+	nodes := make(map[string]bool)
+	addNode := func(id, name, repository string) {
+		if !nodes[id] {
+			nodes[id] = true
+			g.Nodes = append(g.Nodes, &GraphNode{ID: id, Name: name, Repository: repository})
+		}
+	}
+
+	// Transitive dependencies of direct dependencies are attached to their node.
+	directIDs := make(map[string]string)
+	for _, r := range results {
+		if len(r.Path) > 0 {
+			continue
+		}
+
+		from := chartNodeID(rootPath, r.ChartPath)
+		addNode(from, filepath.Base(r.ChartPath), "")
+
+		to := dependencyNodeID(rootPath, r)
+		if strings.HasPrefix(r.Repository, filePrefix) {
+			addNode(to, r.Name, "")
+		} else {
+			addNode(to, r.Name, r.Repository)
+		}
+
+		g.Edges = append(g.Edges, newGraphEdge(from, to, r))
+		directIDs[r.ChartPath+graphPathSeparator+r.Name] = to
+	}
+
+	for _, r := range results {
+		if len(r.Path) < 3 {
+			continue
+		}
+
+		chartID := chartNodeID(rootPath, r.ChartPath)
+		addNode(chartID, filepath.Base(r.ChartPath), "")
+
+		// Path is [chart subchart... dependency].
+		parentPath := r.Path[1 : len(r.Path)-1]
+		from, ok := directIDs[r.ChartPath+graphPathSeparator+parentPath[0]]
+		if !ok || len(parentPath) > 1 {
+			from = chartID + graphPathSeparator + strings.Join(parentPath, graphPathSeparator)
+			addNode(from, parentPath[len(parentPath)-1], "")
+		}
+
+		to := chartID + graphPathSeparator + strings.Join(r.Path[1:], graphPathSeparator)
+		addNode(to, r.Name, r.Repository)
+		g.Edges = append(g.Edges, newGraphEdge(from, to, r))
+	}
+	return g
+}
+
+func newGraphEdge(from, to string, r *Result) *GraphEdge {
+	e := &GraphEdge{
+		From:           from,
+		To:             to,
+		Alias:          r.Alias,
+		Version:        r.Version,
+		CurrentVersion: r.CurrentVersion.String(),
+		LatestVersion:  r.LatestVersion.String(),
+		Outdated:       r.IsOutdated(),
+	}
+	if e.Outdated {
+		e.UpdateType = GetIncType(r.CurrentVersion, r.LatestVersion)
+	}
+	return e
+}
+
+func chartNodeID(rootPath, chartPath string) string {
+	if id := relativePath(rootPath, chartPath); id != "." {
+		return id
+	}
+	return filepath.Base(chartPath)
+}
+
+func dependencyNodeID(rootPath string, r *Result) string {
+	// Local dependencies are relative to the chart declaring them.
+	if strings.HasPrefix(r.Repository, filePrefix) {
+		return chartNodeID(rootPath, localChartPath(r.ChartPath, r.Repository))
+	}
+	return strings.TrimSuffix(r.Repository, "/") + "/" + r.Name
+}
+
+// label returns the label of the edge, e.g. "1.2.0" or "1.2.0 -> 1.4.3" if outdated.
+func (e *GraphEdge) label() string {
+	label := e.CurrentVersion
+	if e.Outdated {
+		label = fmt.Sprintf("%s -> %s", e.CurrentVersion, e.LatestVersion)
+	}
+	if e.Alias != "" {
+		label = fmt.Sprintf("%s (%s)", label, e.Alias)
+	}
+	return label
+}
+
+// DOT returns the graph in the DOT language of Graphviz. Outdated edges are red.
+func (g *Graph) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph dependencies {\n")
+	b.WriteString("  rankdir=LR;\n")
+	for _, n := range g.Nodes {
+		fmt.Fprintf(&b, "  %s [label=%s];\n", dotQuote(n.ID), dotQuote(n.Name))
+	}
+	for _, e := range g.Edges {
+		attrs := "label=" + dotQuote(e.label())
+		if e.Outdated {
+			attrs += fmt.Sprintf(", color=%s, fontcolor=%s", graphOutdatedColor, graphOutdatedColor)
+		}
+		fmt.Fprintf(&b, "  %s -> %s [%s];\n", dotQuote(e.From), dotQuote(e.To), attrs)
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// Mermaid returns the graph as Mermaid flowchart. Outdated edges are red.
+func (g *Graph) Mermaid() string {
+	ids := make(map[string]string, len(g.Nodes))
+	var b strings.Builder
+	b.WriteString("graph LR\n")
+	for idx, n := range g.Nodes {
+		ids[n.ID] = fmt.Sprintf("n%d", idx)
+		fmt.Fprintf(&b, "  %s[%s]\n", ids[n.ID], mermaidQuote(n.Name))
+	}
+
+	var outdated []string
+	for idx, e := range g.Edges {
+		fmt.Fprintf(&b, "  %s -->|%s| %s\n", ids[e.From], mermaidQuote(e.label()), ids[e.To])
+		if e.Outdated {
+			outdated = append(outdated, fmt.Sprintf("%d", idx))
+		}
+	}
+	if len(outdated) > 0 {
+		fmt.Fprintf(&b, "  linkStyle %s stroke:%s,color:%s\n", strings.Join(outdated, ","), graphOutdatedColor, graphOutdatedColor)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+func mermaidQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "#quot;") + `"`
+}
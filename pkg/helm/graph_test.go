@@ -0,0 +1,142 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package helm
+
+import (
+	"testing"
+
+	"github.com/Masterminds/semver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/helm/pkg/chartutil"
+)
+
+func TestNewGraph(t *testing.T) {
+	results := []*Result{
+		{
+			Dependency:     &chartutil.Dependency{Name: "prometheus-operator", Repository: "https://repo.evil.corp", Version: "8.0.0"},
+			ChartPath:      "/charts/umbrella",
+			CurrentVersion: semver.MustParse("8.0.0"),
+			LatestVersion:  semver.MustParse("8.1.0"),
+		},
+		{
+			Dependency:     &chartutil.Dependency{Name: "common", Repository: "file:///charts/common", Version: "0.1.0"},
+			ChartPath:      "/charts/umbrella",
+			CurrentVersion: semver.MustParse("0.1.0"),
+			LatestVersion:  semver.MustParse("0.1.0"),
+		},
+		{
+			Dependency:     &chartutil.Dependency{Name: "redis", Repository: "https://repo.evil.corp/", Version: "~1.2.0"},
+			ChartPath:      "/charts/common",
+			CurrentVersion: semver.MustParse("1.2.3"),
+			LatestVersion:  semver.MustParse("2.0.0"),
+		},
+		{
+			Dependency:     &chartutil.Dependency{Name: "kube-state-metrics", Repository: "https://repo.evil.corp", Version: "2.3.1"},
+			ChartPath:      "/charts/umbrella",
+			Path:           []string{"umbrella", "prometheus-operator", "kube-state-metrics"},
+			CurrentVersion: semver.MustParse("2.3.1"),
+			LatestVersion:  semver.MustParse("2.3.1"),
+		},
+	}
+
+	g := NewGraph("/charts", results)
+
+	ids := make([]string, 0, len(g.Nodes))
+	for _, n := range g.Nodes {
+		ids = append(ids, n.ID)
+	}
+	assert.Equal(t,
+		[]string{"umbrella", "https://repo.evil.corp/prometheus-operator", "common", "https://repo.evil.corp/redis", "umbrella > prometheus-operator > kube-state-metrics"},
+		ids,
+		"local dependencies should point to the scanned chart",
+	)
+
+	require.Len(t, g.Edges, 4)
+	assert.Equal(t, &GraphEdge{
+		From:           "umbrella",
+		To:             "https://repo.evil.corp/prometheus-operator",
+		Version:        "8.0.0",
+		CurrentVersion: "8.0.0",
+		LatestVersion:  "8.1.0",
+		Outdated:       true,
+		UpdateType:     IncTypes.Minor,
+	}, g.Edges[0])
+	assert.False(t, g.Edges[1].Outdated)
+	assert.Equal(t, "common", g.Edges[2].From)
+	assert.Equal(t, "https://repo.evil.corp/prometheus-operator", g.Edges[3].From, "transitive dependencies should be attached to the direct dependency")
+
+	assert.Equal(t, `digraph dependencies {
+  rankdir=LR;
+  "umbrella" [label="umbrella"];
+  "https://repo.evil.corp/prometheus-operator" [label="prometheus-operator"];
+  "common" [label="common"];
+  "https://repo.evil.corp/redis" [label="redis"];
+  "umbrella > prometheus-operator > kube-state-metrics" [label="kube-state-metrics"];
+  "umbrella" -> "https://repo.evil.corp/prometheus-operator" [label="8.0.0 -> 8.1.0", color=red, fontcolor=red];
+  "umbrella" -> "common" [label="0.1.0"];
+  "common" -> "https://repo.evil.corp/redis" [label="1.2.3 -> 2.0.0", color=red, fontcolor=red];
+  "https://repo.evil.corp/prometheus-operator" -> "umbrella > prometheus-operator > kube-state-metrics" [label="2.3.1"];
+}`, g.DOT())
+
+	assert.Equal(t, `graph LR
+  n0["umbrella"]
+  n1["prometheus-operator"]
+  n2["common"]
+  n3["redis"]
+  n4["kube-state-metrics"]
+  n0 -->|"8.0.0 -> 8.1.0"| n1
+  n0 -->|"0.1.0"| n2
+  n2 -->|"1.2.3 -> 2.0.0"| n3
+  n1 -->|"2.3.1"| n4
+  linkStyle 0,2 stroke:red,color:red`, g.Mermaid())
+}
+
+func TestNewGraphRelativeLocalDependencies(t *testing.T) {
+	newResult := func(chartPath, name, repository string) *Result {
+		return &Result{
+			Dependency:     &chartutil.Dependency{Name: name, Repository: repository, Version: "0.1.0"},
+			ChartPath:      chartPath,
+			CurrentVersion: semver.MustParse("0.1.0"),
+			LatestVersion:  semver.MustParse("0.1.0"),
+		}
+	}
+
+	g := NewGraph("/charts", []*Result{
+		newResult("/charts/a/umbrella", "common", "file://../common"),
+		newResult("/charts/b/umbrella", "common", "file://../common"),
+		newResult("/charts/b/umbrella", "library", "file://./library"),
+		newResult("/charts/b/common", "library", "file://../umbrella/library"),
+	})
+
+	ids := make([]string, 0, len(g.Nodes))
+	for _, n := range g.Nodes {
+		ids = append(ids, n.ID)
+	}
+	assert.Equal(t,
+		[]string{"a/umbrella", "a/common", "b/umbrella", "b/common", "b/umbrella/library"},
+		ids,
+		"local dependencies should be resolved relative to the chart declaring them",
+	)
+
+	require.Len(t, g.Edges, 4)
+	assert.Equal(t, "b/common", g.Edges[3].From, "the local dependency should point to the node of the chart")
+	assert.Equal(t, "b/umbrella/library", g.Edges[3].To)
+}
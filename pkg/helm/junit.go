@@ -0,0 +1,105 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package helm
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+// junitFailureDeprecated is the failure type of deprecated dependencies, which are up to date. Outdated ones use the update type.
+const junitFailureDeprecated = "deprecated"
+
+// JUnitTestSuites is the root element of a JUnit XML report.
+type JUnitTestSuites struct {
+	XMLName    xml.Name          `xml:"testsuites"`
+	Name       string            `xml:"name,attr"`
+	Tests      int               `xml:"tests,attr"`
+	Failures   int               `xml:"failures,attr"`
+	TestSuites []*JUnitTestSuite `xml:"testsuite"`
+}
+
+// JUnitTestSuite contains the test cases of a chart.
+type JUnitTestSuite struct {
+	Name      string           `xml:"name,attr"`
+	Tests     int              `xml:"tests,attr"`
+	Failures  int              `xml:"failures,attr"`
+	TestCases []*JUnitTestCase `xml:"testcase"`
+}
+
+// JUnitTestCase is a single dependency, which fails if outdated.
+type JUnitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr,omitempty"`
+	Line      int           `xml:"line,attr,omitempty"`
+	Failure   *JUnitFailure `xml:"failure,omitempty"`
+}
+
+// JUnitFailure describes an outdated dependency.
+type JUnitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// NewJUnitReport returns a JUnit report with one test suite per chart and one test case per dependency.
+// Outdated and deprecated dependencies are reported as failures. Paths are made relative to the given root path.
+func NewJUnitReport(rootPath string, results []*Result) *JUnitTestSuites {
+	report := &JUnitTestSuites{Name: "helm-outdated-dependencies"}
+
+	chartPaths, resultsByChart := GroupResultsByChart(results)
+	for _, chartPath := range chartPaths {
+		suite := &JUnitTestSuite{Name: relativePath(rootPath, chartPath)}
+		for _, r := range resultsByChart[chartPath] {
+			tc := &JUnitTestCase{
+				Name:      r.displayName(),
+				ClassName: suite.Name,
+				File:      relativePath(rootPath, r.File),
+				Line:      r.Line,
+			}
+
+			if r.IsOutdated() {
+				incType := GetIncType(r.CurrentVersion, r.LatestVersion)
+				tc.Failure = &JUnitFailure{
+					Message: fmt.Sprintf("%s %s is outdated, latest version is %s", r.displayName(), r.CurrentVersion.String(), r.LatestVersion.String()),
+					Type:    string(incType),
+					Text:    fmt.Sprintf("%s update of %s from repository %s available: %s -> %s", incType, r.Name, r.Repository, r.Version, r.LatestVersion.String()),
+				}
+				suite.Failures++
+			} else if r.Deprecated {
+				tc.Failure = &JUnitFailure{
+					Message: fmt.Sprintf("%s %s is deprecated", r.displayName(), r.CurrentVersion.String()),
+					Type:    junitFailureDeprecated,
+					Text:    deprecationMessage(r),
+				}
+				suite.Failures++
+			}
+
+			suite.TestCases = append(suite.TestCases, tc)
+			suite.Tests++
+		}
+
+		report.TestSuites = append(report.TestSuites, suite)
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+	}
+	return report
+}
@@ -0,0 +1,152 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package helm
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// hoursPerYear is the average number of hours of a year used for libyears.
+const hoursPerYear = 365.25 * 24
+
+// SortKey is one of SortKeys.
+type SortKey string
+
+// SortKeys enumerates the keys results can be sorted by.
+var SortKeys = struct {
+	Name,
+	Libyear,
+	VersionsBehind,
+	ReleaseDate SortKey
+}{
+	"name",
+	"libyear",
+	"versions-behind",
+	"release-date",
+}
+
+// ParseSortKey parses the given sort key, which is one of name, libyear, versions-behind or release-date.
+func ParseSortKey(sortKey string) (SortKey, error) {
+	switch k := SortKey(strings.ToLower(sortKey)); k {
+	case SortKeys.Name, SortKeys.Libyear, SortKeys.VersionsBehind, SortKeys.ReleaseDate:
+		return k, nil
+	}
+	return "", errors.Errorf("unknown sort key %s, must be one of name, libyear, versions-behind, release-date", sortKey)
+}
+
+// HasReleaseDates checks whether the release dates of the current and latest version are known.
+func (r *Result) HasReleaseDates() bool {
+	return !r.CurrentReleaseDate.IsZero() && !r.LatestReleaseDate.IsZero()
+}
+
+// Libyear returns the time between the releases of the current and the latest version in years or 0 if unknown.
+func (r *Result) Libyear() float64 {
+	if !r.HasReleaseDates() || !r.CurrentReleaseDate.Before(r.LatestReleaseDate) {
+		return 0
+	}
+	return r.LatestReleaseDate.Sub(r.CurrentReleaseDate).Hours() / hoursPerYear
+}
+
+// ChartMetrics aggregate the metrics of the dependencies of a chart.
+type ChartMetrics struct {
+	ChartPath string
+
+	// Dependencies is the number of dependencies and Outdated the number of outdated ones.
+	Dependencies,
+	Outdated int
+
+	// VersionsBehind and Libyear are the sums of all dependencies.
+	VersionsBehind int
+	Libyear        float64
+}
+
+// AggregateByChart returns the metrics of each chart in the order of the results.
+func AggregateByChart(results []*Result) []*ChartMetrics {
+	chartPaths, resultsByChart := GroupResultsByChart(results)
+	metrics := make([]*ChartMetrics, 0, len(chartPaths))
+	for _, chartPath := range chartPaths {
+		m := &ChartMetrics{ChartPath: chartPath}
+		for _, r := range resultsByChart[chartPath] {
+			m.Dependencies++
+			if r.IsOutdated() {
+				m.Outdated++
+			}
+			m.VersionsBehind += r.VersionsBehind
+			m.Libyear += r.Libyear()
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics
+}
+
+// FilterBehind returns only the outdated results at least the given libyears or number of versions behind.
+// Deprecated dependencies are always kept, since they are reported even if they are on their latest version.
+// A threshold of 0 disables the filter.
+func FilterBehind(results []*Result, minLibyear float64, minVersionsBehind int) []*Result {
+	var res []*Result
+	for _, r := range results {
+		if !r.Deprecated {
+			if minLibyear > 0 && r.Libyear() < minLibyear {
+				continue
+			}
+			if minVersionsBehind > 0 && r.VersionsBehind < minVersionsBehind {
+				continue
+			}
+		}
+		res = append(res, r)
+	}
+	return res
+}
+
+// SortResults sorts the results of each chart by the given key. The charts are sorted by their path.
+// Libyears and versions behind are sorted descending, release dates ascending, so the most outdated dependencies come first. Ties keep their order.
+func SortResults(results []*Result, sortKey SortKey) []*Result {
+	var less func(a, b *Result) bool
+	switch sortKey {
+	case SortKeys.Libyear:
+		less = func(a, b *Result) bool { return a.Libyear() > b.Libyear() }
+	case SortKeys.VersionsBehind:
+		less = func(a, b *Result) bool { return a.VersionsBehind > b.VersionsBehind }
+	case SortKeys.ReleaseDate:
+		less = func(a, b *Result) bool { return releaseDateBefore(a.CurrentReleaseDate, b.CurrentReleaseDate) }
+	default:
+		return sortResultsAlphabetically(results)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].ChartPath != results[j].ChartPath {
+			return results[i].ChartPath < results[j].ChartPath
+		}
+		return less(results[i], results[j])
+	})
+	return results
+}
+
+// releaseDateBefore compares release dates. Unknown dates come last.
+func releaseDateBefore(a, b time.Time) bool {
+	if a.IsZero() || b.IsZero() {
+		return !a.IsZero() && b.IsZero()
+	}
+	return a.Before(b)
+}
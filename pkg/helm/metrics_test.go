@@ -0,0 +1,123 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package helm
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/helm/pkg/chartutil"
+)
+
+const metricsIndexFile = `apiVersion: v1
+entries:
+  grafana:
+  - name: grafana
+    version: 2.0.0
+    created: "2020-01-01T00:00:00Z"
+  - name: grafana
+    version: 1.2.0-rc.1
+    created: "2019-09-01T00:00:00Z"
+  - name: grafana
+    version: 1.1.0
+    created: "2019-07-01T00:00:00Z"
+  - name: grafana
+    version: 1.0.0
+    created: "2019-01-01T00:00:00Z"
+`
+
+func TestEvaluateDependencyMetrics(t *testing.T) {
+	helmSettings, cleanup := newHelmSettings(t)
+	defer cleanup()
+	require.NoError(t, os.MkdirAll(helmSettings.Home.Cache(), 0755))
+
+	repos, err := loadRepositories(helmSettings)
+	require.NoError(t, err)
+	e, err := repos.entry("@stable")
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(repos.cacheIndexFile(e), []byte(metricsIndexFile), 0644))
+
+	r, err := evaluateDependency(&chartutil.Dependency{Name: "grafana", Repository: "@stable", Version: "1.0.0"}, &Policy{}, repos, nil)
+	require.NoError(t, err, "there should be no error evaluating the dependency")
+	assert.Equal(t, time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC), r.CurrentReleaseDate.UTC())
+	assert.Equal(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), r.LatestReleaseDate.UTC())
+	assert.Equal(t, 2, r.VersionsBehind, "pre-releases should not be counted")
+	assert.InDelta(t, 1.0, r.Libyear(), 0.01)
+
+	// Only the versions up to the latest one allowed by the policy are counted.
+	policy := &Policy{Dependencies: []*DependencyPolicy{{Name: "grafana", Pin: "1"}}}
+	r, err = evaluateDependency(&chartutil.Dependency{Name: "grafana", Repository: "@stable", Version: "1.0.0"}, policy, repos, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, r.VersionsBehind)
+	assert.InDelta(t, 0.5, r.Libyear(), 0.01)
+}
+
+func TestSortAndFilterResultsByMetrics(t *testing.T) {
+	newResult := func(name, chartPath string, versionsBehind int, current, latest time.Time) *Result {
+		return &Result{
+			Dependency:         &chartutil.Dependency{Name: name},
+			ChartPath:          chartPath,
+			CurrentVersion:     semver.MustParse("1.0.0"),
+			LatestVersion:      semver.MustParse("1.0.1"),
+			CurrentReleaseDate: current,
+			LatestReleaseDate:  latest,
+			VersionsBehind:     versionsBehind,
+		}
+	}
+
+	a := newResult("a", "/charts/one", 2, time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC), time.Date(2019, 12, 1, 0, 0, 0, 0, time.UTC))
+	b := newResult("b", "/charts/one", 10, time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2019, 12, 1, 0, 0, 0, 0, time.UTC))
+	c := newResult("c", "/charts/two", 1, time.Time{}, time.Time{})
+
+	assert.Equal(t, []*Result{b, a, c}, SortResults([]*Result{a, b, c}, SortKeys.Libyear))
+	assert.Equal(t, []*Result{b, a, c}, SortResults([]*Result{c, a, b}, SortKeys.VersionsBehind))
+	assert.Equal(t, []*Result{b, a, c}, SortResults([]*Result{c, a, b}, SortKeys.ReleaseDate), "unknown release dates should come last")
+	assert.Equal(t, []*Result{a, b, c}, SortResults([]*Result{c, b, a}, SortKeys.Name))
+
+	// The charts are not mixed.
+	d := newResult("d", "/charts/two", 20, time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2019, 12, 1, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, []*Result{b, a, d, c}, SortResults([]*Result{d, c, a, b}, SortKeys.Libyear), "the results should be sorted per chart")
+	assert.Equal(t, []*Result{b, a, d, c}, SortResults([]*Result{c, d, b, a}, SortKeys.VersionsBehind))
+	assert.Equal(t, []*Result{b, a, d, c}, SortResults([]*Result{c, a, d, b}, SortKeys.ReleaseDate))
+
+	assert.Equal(t, []*Result{b}, FilterBehind([]*Result{a, b, c}, 1, 0))
+	assert.Equal(t, []*Result{a, b}, FilterBehind([]*Result{a, b, c}, 0, 2))
+	assert.Equal(t, []*Result{a, b, c}, FilterBehind([]*Result{a, b, c}, 0, 0))
+
+	// A deprecated dependency on its latest version is neither behind nor outdated.
+	deprecated := &Result{Dependency: &chartutil.Dependency{Name: "e"}, ChartPath: "/charts/two", CurrentVersion: semver.MustParse("1.0.0"), LatestVersion: semver.MustParse("1.0.0"), Deprecated: true}
+	assert.Equal(t, []*Result{b, deprecated}, FilterBehind([]*Result{a, b, c, deprecated}, 1, 0), "deprecated dependencies should always be kept")
+	assert.Equal(t, []*Result{a, b, deprecated}, FilterBehind([]*Result{a, b, c, deprecated}, 0, 2), "deprecated dependencies should always be kept")
+
+	metrics := AggregateByChart([]*Result{a, b, c})
+	require.Len(t, metrics, 2)
+	assert.Equal(t, "/charts/one", metrics[0].ChartPath)
+	assert.Equal(t, 12, metrics[0].VersionsBehind)
+	assert.InDelta(t, a.Libyear()+b.Libyear(), metrics[0].Libyear, 0.001)
+	assert.Equal(t, 0.0, metrics[1].Libyear, "unknown release dates should not count")
+
+	_, err := ParseSortKey("age")
+	assert.Error(t, err)
+}
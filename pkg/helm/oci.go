@@ -0,0 +1,372 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package helm
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/helm/pkg/chartutil"
+	"k8s.io/helm/pkg/proto/hapi/chart"
+	"k8s.io/helm/pkg/tlsutil"
+)
+
+const (
+	ociPrefix            = "oci://"
+	ociManifestMediaType = "application/vnd.oci.image.manifest.v1+json"
+
+	// ociChartLayerMediaType is the media type of the chart archive pushed by Helm 3.8 and newer.
+	// Older versions of Helm used ociLegacyChartLayerMediaType.
+	ociChartLayerMediaType       = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+	ociLegacyChartLayerMediaType = "application/tar+gzip"
+)
+
+// ociClient lists the tags of charts in OCI registries and pulls charts using the distribution API.
+type ociClient struct {
+	httpClient *http.Client
+
+	// tokens caches the bearer tokens by host and repository, so a token is never sent to another registry.
+	tokensMtx sync.Mutex
+	tokens    map[string]string
+}
+
+var defaultOCIClient = newOCIClient(&http.Client{Timeout: 30 * time.Second})
+
+func newOCIClient(httpClient *http.Client) *ociClient {
+	return &ociClient{
+		httpClient: httpClient,
+		tokens:     make(map[string]string),
+	}
+}
+
+func isOCIRepository(repository string) bool {
+	return strings.HasPrefix(repository, ociPrefix)
+}
+
+// checkOCIDependencies returns an error if an apiVersion v1 chart has dependencies in OCI registries.
+// Helm 2 cannot download these, so the requirements.lock and charts/ folder of such a chart cannot be updated.
+func checkOCIDependencies(c *chart.Chart, reqs *chartutil.Requirements) error {
+	if isAPIVersionV2(c) {
+		return nil
+	}
+
+	for _, dep := range reqs.Dependencies {
+		if isOCIRepository(dep.Repository) {
+			return errors.Errorf("dependency %s in OCI registry %s is only supported for apiVersion v2 charts", dep.Name, dep.Repository)
+		}
+	}
+	return nil
+}
+
+// parseOCIReference returns the host of the registry and the name of the repository of the chart in the OCI repository.
+func parseOCIReference(repository, chartName string) (string, string, error) {
+	ref := strings.TrimSuffix(strings.TrimPrefix(repository, ociPrefix), "/") + "/" + chartName
+	parts := strings.SplitN(ref, "/", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return "", "", errors.Errorf("invalid OCI repository %s", repository)
+	}
+	return parts[0], parts[1], nil
+}
+
+// listTags returns the tags of the chart with the given name in the OCI repository using the given credentials, if any.
+// Helm replaces the "+" of a version with "_" in tags, which is reverted here.
+func (c *ociClient) listTags(repository, chartName string, auth *RepositoryAuth) ([]string, error) {
+	host, repo, err := parseOCIReference(repository, chartName)
+	if err != nil {
+		return nil, err
+	}
+
+	next := fmt.Sprintf("https://%s/v2/%s/tags/list", host, repo)
+	var tags []string
+	for next != "" {
+		res, err := c.get(next, host, repo, "application/json", auth)
+		if err != nil {
+			return nil, err
+		}
+
+		var tagList struct {
+			Tags []string `json:"tags"`
+		}
+		err = json.NewDecoder(res.Body).Decode(&tagList)
+		res.Body.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "error decoding tags of %s/%s", host, repo)
+		}
+
+		for _, t := range tagList.Tags {
+			tags = append(tags, strings.ReplaceAll(t, "_", "+"))
+		}
+
+		if next, err = nextLink(res.Request.URL, res.Header.Get("Link")); err != nil {
+			return nil, err
+		}
+	}
+	return tags, nil
+}
+
+// pullChart downloads the archive of the given version of the chart from the OCI repository and verifies its digest.
+func (c *ociClient) pullChart(repository, chartName, version string, auth *RepositoryAuth) ([]byte, error) {
+	host, repo, err := parseOCIReference(repository, chartName)
+	if err != nil {
+		return nil, err
+	}
+
+	tag := strings.ReplaceAll(version, "+", "_")
+	res, err := c.get(fmt.Sprintf("https://%s/v2/%s/manifests/%s", host, repo, tag), host, repo, ociManifestMediaType, auth)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest struct {
+		Layers []struct {
+			MediaType string `json:"mediaType"`
+			Digest    string `json:"digest"`
+		} `json:"layers"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&manifest)
+	res.Body.Close()
+	if err != nil {
+		return nil, errors.Wrapf(err, "error decoding manifest of %s/%s:%s", host, repo, tag)
+	}
+
+	var digest string
+	for _, l := range manifest.Layers {
+		if l.MediaType == ociChartLayerMediaType || l.MediaType == ociLegacyChartLayerMediaType {
+			digest = l.Digest
+			break
+		}
+	}
+	if !strings.HasPrefix(digest, "sha256:") {
+		return nil, errors.Errorf("no chart layer with sha256 digest found in manifest of %s/%s:%s", host, repo, tag)
+	}
+
+	if res, err = c.get(fmt.Sprintf("https://%s/v2/%s/blobs/%s", host, repo, digest), host, repo, "", auth); err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error downloading chart %s/%s:%s", host, repo, tag)
+	}
+
+	sum := sha256.Sum256(data)
+	if "sha256:"+hex.EncodeToString(sum[:]) != digest {
+		return nil, errors.Errorf("digest of chart %s/%s:%s does not match %s", host, repo, tag, digest)
+	}
+	return data, nil
+}
+
+// get requests the given URL of the repository in the registry on the given host using the given credentials, if any.
+// If the registry asks for authentication, a bearer token is requested or basic authentication is used and the request is retried.
+func (c *ociClient) get(u, host, repo, accept string, auth *RepositoryAuth) (*http.Response, error) {
+	client, err := c.clientFor(u, auth)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.doGet(client, u, accept, c.authorization(host, repo, auth))
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode == http.StatusUnauthorized {
+		challenge := res.Header.Get("WWW-Authenticate")
+		res.Body.Close()
+
+		authorization, err := c.authorize(client, challenge, host, repo, auth)
+		if err != nil {
+			return nil, err
+		}
+
+		if res, err = c.doGet(client, u, accept, authorization); err != nil {
+			return nil, err
+		}
+	}
+
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, errors.Errorf("unexpected status %s requesting %s", res.Status, u)
+	}
+	return res, nil
+}
+
+func (c *ociClient) doGet(client *http.Client, u, accept, authorization string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	return client.Do(req)
+}
+
+// clientFor returns the HTTP client for the given URL, which uses the client certificate and CA of the credentials, if any.
+func (c *ociClient) clientFor(u string, auth *RepositoryAuth) (*http.Client, error) {
+	if auth == nil || (auth.CAFile == "" && (auth.CertFile == "" || auth.KeyFile == "")) {
+		return c.httpClient, nil
+	}
+
+	tlsConf, err := tlsutil.NewTLSConfig(u, auth.CertFile, auth.KeyFile, auth.CAFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't create TLS config")
+	}
+	return &http.Client{
+		Transport: &http.Transport{TLSClientConfig: tlsConf, Proxy: http.ProxyFromEnvironment},
+		Timeout:   c.httpClient.Timeout,
+	}, nil
+}
+
+// authorization returns the value of the Authorization header for the first request to the repository.
+// A cached bearer token takes precedence over the configured one.
+func (c *ociClient) authorization(host, repo string, auth *RepositoryAuth) string {
+	if token := c.getToken(host, repo); token != "" {
+		return "Bearer " + token
+	}
+	if auth != nil && auth.Token != "" {
+		return "Bearer " + auth.Token
+	}
+	return ""
+}
+
+// authorize returns the value of the Authorization header answering the authentication challenge of the registry.
+func (c *ociClient) authorize(client *http.Client, challenge, host, repo string, auth *RepositoryAuth) (string, error) {
+	if strings.HasPrefix(challenge, "Basic ") {
+		authorization := basicAuthorization(auth)
+		if authorization == "" {
+			return "", errors.New("registry requires basic authentication, but no username and password are configured")
+		}
+		return authorization, nil
+	}
+
+	token, err := c.fetchToken(client, challenge, host, repo, auth)
+	if err != nil {
+		return "", err
+	}
+	return "Bearer " + token, nil
+}
+
+// fetchToken requests a pull token as described by the bearer challenge of the registry.
+// The token is requested anonymously unless a username and password are configured.
+func (c *ociClient) fetchToken(client *http.Client, challenge, host, repo string, auth *RepositoryAuth) (string, error) {
+	if !strings.HasPrefix(challenge, "Bearer ") {
+		return "", errors.Errorf("unsupported authentication challenge %q", challenge)
+	}
+
+	params := parseChallengeParams(strings.TrimPrefix(challenge, "Bearer "))
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return "", errors.Errorf("invalid realm in authentication challenge %q", challenge)
+	}
+
+	q := realm.Query()
+	if service, ok := params["service"]; ok {
+		q.Set("service", service)
+	}
+	q.Set("scope", fmt.Sprintf("repository:%s:pull", repo))
+	realm.RawQuery = q.Encode()
+
+	res, err := c.doGet(client, realm.String(), "application/json", basicAuthorization(auth))
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", errors.Errorf("unexpected status %s requesting token from %s", res.Status, realm.String())
+	}
+
+	var tokenResponse struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&tokenResponse); err != nil {
+		return "", errors.Wrap(err, "error decoding token")
+	}
+
+	token := tokenResponse.Token
+	if token == "" {
+		token = tokenResponse.AccessToken
+	}
+
+	c.tokensMtx.Lock()
+	c.tokens[host+"/"+repo] = token
+	c.tokensMtx.Unlock()
+	return token, nil
+}
+
+// basicAuthorization returns the value of the Authorization header for basic authentication or an empty string if there is no username and password.
+func basicAuthorization(auth *RepositoryAuth) string {
+	if auth == nil || auth.Username == "" || auth.Password == "" {
+		return ""
+	}
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(auth.Username+":"+auth.Password))
+}
+
+func (c *ociClient) getToken(host, repo string) string {
+	c.tokensMtx.Lock()
+	defer c.tokensMtx.Unlock()
+	return c.tokens[host+"/"+repo]
+}
+
+// parseChallengeParams parses the parameters of an authentication challenge like `realm="https://auth.corp/token",service="registry.corp"`.
+func parseChallengeParams(s string) map[string]string {
+	params := make(map[string]string)
+	for _, p := range strings.Split(s, ",") {
+		kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+		if len(kv) == 2 {
+			params[strings.ToLower(kv[0])] = strings.Trim(kv[1], `"`)
+		}
+	}
+	return params
+}
+
+// nextLink returns the absolute URL of the next page given by the Link header or an empty string if there is none.
+func nextLink(base *url.URL, link string) (string, error) {
+	if link == "" || !strings.Contains(link, `rel="next"`) {
+		return "", nil
+	}
+
+	start, end := strings.Index(link, "<"), strings.Index(link, ">")
+	if start < 0 || end < start {
+		return "", errors.Errorf("invalid link header %q", link)
+	}
+
+	next, err := base.Parse(link[start+1 : end])
+	if err != nil {
+		return "", err
+	}
+	return next.String(), nil
+}
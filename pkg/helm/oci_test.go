@@ -0,0 +1,193 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package helm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/helm/pkg/chartutil"
+	"k8s.io/helm/pkg/proto/hapi/chart"
+)
+
+// testRegistryChart is the archive of the chart charts/prometheus-operator:8.1.0 in the test registry.
+var testRegistryChart = []byte("prometheus-operator-8.1.0.tgz")
+
+// newTestRegistry returns a registry stand-in serving the tags of the chart charts/prometheus-operator in two pages.
+// Listing tags requires a bearer token, which is handed out anonymously.
+// Pulling the chart requires a bearer token, which is only handed out to the user "ci" with the password "password".
+func newTestRegistry(t *testing.T) *httptest.Server {
+	sum := sha256.Sum256(testRegistryChart)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+
+	var srv *httptest.Server
+	srv = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requireToken := func(token string) bool {
+			if r.Header.Get("Authorization") == "Bearer "+token {
+				return true
+			}
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry"`, srv.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return false
+		}
+
+		switch r.URL.Path {
+		case "/token":
+			assert.Equal(t, "repository:charts/prometheus-operator:pull", r.URL.Query().Get("scope"))
+			if username, password, ok := r.BasicAuth(); ok && username == "ci" && password == "password" {
+				fmt.Fprint(w, `{"token": "private"}`)
+				return
+			}
+			fmt.Fprint(w, `{"token": "secret"}`)
+
+		case "/v2/charts/prometheus-operator/manifests/8.1.0":
+			if requireToken("private") {
+				assert.Equal(t, ociManifestMediaType, r.Header.Get("Accept"))
+				fmt.Fprintf(w, `{"layers": [{"mediaType": "%s", "digest": "%s"}]}`, ociChartLayerMediaType, digest)
+			}
+
+		case "/v2/charts/prometheus-operator/blobs/" + digest:
+			if requireToken("private") {
+				w.Write(testRegistryChart)
+			}
+
+		case "/v2/charts/prometheus-operator/tags/list":
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry"`, srv.URL))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			if r.URL.Query().Get("last") == "" {
+				w.Header().Set("Link", `</v2/charts/prometheus-operator/tags/list?last=8.2.0>; rel="next"`)
+				fmt.Fprint(w, `{"name": "charts/prometheus-operator", "tags": ["8.1.0", "8.2.0"]}`)
+				return
+			}
+			fmt.Fprint(w, `{"name": "charts/prometheus-operator", "tags": ["9.0.0-rc.1", "9.0.0_build.1", "latest"]}`)
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return srv
+}
+
+func TestOCIClientListTags(t *testing.T) {
+	srv := newTestRegistry(t)
+	defer srv.Close()
+
+	client := newOCIClient(srv.Client())
+	repository := ociPrefix + strings.TrimPrefix(srv.URL, "https://") + "/charts"
+
+	tags, err := client.listTags(repository, "prometheus-operator", nil)
+	require.NoError(t, err, "there should be no error listing the tags")
+	assert.Equal(t, []string{"8.1.0", "8.2.0", "9.0.0-rc.1", "9.0.0+build.1", "latest"}, tags, "all pages should be listed and build metadata restored")
+
+	_, err = client.listTags(repository, "grafana", nil)
+	assert.Error(t, err, "listing the tags of an unknown chart should fail")
+}
+
+func TestOCIClientTokensPerRegistry(t *testing.T) {
+	srv := newTestRegistry(t)
+	defer srv.Close()
+
+	// Another registry serving the same repository, which hands out its own token.
+	var authorizations []string
+	var other *httptest.Server
+	other = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			fmt.Fprint(w, `{"token": "other"}`)
+		case "/v2/charts/prometheus-operator/tags/list":
+			authorizations = append(authorizations, r.Header.Get("Authorization"))
+			if r.Header.Get("Authorization") != "Bearer other" {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="other"`, other.URL))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"name": "charts/prometheus-operator", "tags": ["1.0.0"]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer other.Close()
+
+	// Both test servers use the same certificate.
+	client := newOCIClient(srv.Client())
+	_, err := client.listTags(ociPrefix+strings.TrimPrefix(srv.URL, "https://")+"/charts", "prometheus-operator", nil)
+	require.NoError(t, err)
+
+	tags, err := client.listTags(ociPrefix+strings.TrimPrefix(other.URL, "https://")+"/charts", "prometheus-operator", nil)
+	require.NoError(t, err, "there should be no error listing the tags of the other registry")
+	assert.Equal(t, []string{"1.0.0"}, tags)
+	assert.Equal(t, []string{"", "Bearer other"}, authorizations, "the token of a registry must not be sent to another registry with the same repository")
+}
+
+func TestOCIClientPullChart(t *testing.T) {
+	srv := newTestRegistry(t)
+	defer srv.Close()
+
+	client := newOCIClient(srv.Client())
+	repository := ociPrefix + strings.TrimPrefix(srv.URL, "https://") + "/charts"
+
+	_, err := client.pullChart(repository, "prometheus-operator", "8.1.0", nil)
+	assert.Error(t, err, "pulling the chart without credentials should fail")
+
+	data, err := client.pullChart(repository, "prometheus-operator", "8.1.0", &RepositoryAuth{URL: repository, Username: "ci", Password: "password"})
+	require.NoError(t, err, "there should be no error pulling the chart with credentials")
+	assert.Equal(t, testRegistryChart, data)
+}
+
+func TestCheckOCIDependencies(t *testing.T) {
+	reqs := &chartutil.Requirements{Dependencies: []*chartutil.Dependency{{Name: "prometheus-operator", Repository: "oci://registry.corp/charts"}}}
+
+	err := checkOCIDependencies(&chart.Chart{Metadata: &chart.Metadata{ApiVersion: "v1"}}, reqs)
+	assert.Error(t, err, "dependencies in OCI registries should be rejected for apiVersion v1 charts")
+	assert.NoError(t, checkOCIDependencies(&chart.Chart{Metadata: &chart.Metadata{ApiVersion: apiVersionV2}}, reqs))
+}
+
+func TestFindVersionsOfOCIDependency(t *testing.T) {
+	srv := newTestRegistry(t)
+	defer srv.Close()
+
+	defer func(c *ociClient) { defaultOCIClient = c }(defaultOCIClient)
+	defaultOCIClient = newOCIClient(srv.Client())
+
+	dep := &chartutil.Dependency{
+		Name:       "prometheus-operator",
+		Repository: ociPrefix + strings.TrimPrefix(srv.URL, "https://") + "/charts",
+		Version:    "8.1.0",
+	}
+
+	versions, err := findVersionsOfDependency(dep, &repositories{})
+	require.NoError(t, err, "there should be no error finding the versions")
+	assert.Equal(t, newVersions("8.1.0", "8.2.0", "9.0.0+build.1"), versions, "only released semantic versions should be found")
+
+	lockedDep, err := resolveDependency("", &chartutil.Dependency{Name: dep.Name, Repository: dep.Repository, Version: "~8.1.0"}, &repositories{})
+	require.NoError(t, err, "there should be no error resolving the dependency")
+	assert.Equal(t, "8.1.0", lockedDep.Version, "the dependency should be locked to the latest matching tag")
+}
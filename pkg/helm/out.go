@@ -22,13 +22,12 @@ package helm
 import (
 	"bytes"
 	"encoding/json"
+	"io/ioutil"
 	"os"
-	"path"
 	"path/filepath"
 
+	"github.com/pkg/errors"
 	yamlv3 "gopkg.in/yaml.v3"
-	"k8s.io/helm/pkg/chartutil"
-	"k8s.io/helm/pkg/proto/hapi/chart"
 )
 
 func toYamlWithIndent(in interface{}, indent int) ([]byte, error) {
@@ -53,48 +52,88 @@ func toYamlWithIndent(in interface{}, indent int) ([]byte, error) {
 	return buf.Bytes(), err
 }
 
-func writeRequirements(chartPath string, reqs *chartutil.Requirements, indent int) error {
-	data, err := toYamlWithIndent(reqs, indent)
-	if err != nil {
+func fromYaml(data []byte, out interface{}) error {
+	// See toYamlWithIndent. The YAML is decoded into a generic object first, which is then mapped to the given struct via JSON.
+	var yamlObj interface{}
+	if err := yamlv3.Unmarshal(data, &yamlObj); err != nil {
 		return err
 	}
 
-	absPath, err := filepath.Abs(path.Join(chartPath, requirementsName))
+	return toMap(yamlObj, out)
+}
+
+// toMap converts the given object to the output object using their JSON representation.
+func toMap(in, out interface{}) error {
+	jsonData, err := json.Marshal(in)
 	if err != nil {
 		return err
 	}
 
-	f, err := os.OpenFile(absPath, os.O_RDWR, 0644)
+	return json.Unmarshal(jsonData, out)
+}
+
+// writeDependencyVersions updates the versions of the given dependencies in the file declaring them.
+func writeDependencyVersions(chartPath, fileName string, depVersions []dependencyVersion) error {
+	return updateFile(filepath.Join(chartPath, fileName), func(data []byte) ([]byte, error) {
+		return setDependencyVersions(data, depVersions)
+	})
+}
+
+// writeChartVersion updates the version in the Chart.yaml.
+func writeChartVersion(chartPath, version string) error {
+	return updateFile(filepath.Join(chartPath, chartMetadataName), func(data []byte) ([]byte, error) {
+		return setChartVersion(data, version)
+	})
+}
+
+// updateFile rewrites the file using the given function while keeping its permissions.
+func updateFile(path string, update func(data []byte) ([]byte, error)) error {
+	fi, err := os.Stat(path)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
 
-	if err := f.Truncate(0); err != nil {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
 		return err
 	}
 
-	_, err = f.Write(data)
-	return err
+	data, err = update(data)
+	if err != nil {
+		return errors.Wrapf(err, "error updating %s", path)
+	}
+
+	return writeFileAtomic(path, data, fi.Mode())
 }
 
-func writeChartMetadata(chartPath string, c *chart.Metadata) error {
-	data, err := toYamlWithIndent(c, 0)
+// writeFileAtomic writes the data to a temporary file next to the given one and renames it afterwards,
+// so the file is never left half-written.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
 	if err != nil {
 		return err
 	}
+	tmpPath := f.Name()
 
-	absPath, err := filepath.Abs(path.Join(chartPath, chartMetadataName))
-	if err != nil {
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
 		return err
 	}
 
-	f, err := os.OpenFile(absPath, os.O_RDWR, 0644)
-	if err != nil {
+	if err := f.Close(); err != nil {
+		os.Remove(tmpPath)
 		return err
 	}
-	defer f.Close()
 
-	_, err = f.WriteAt(data, 0)
-	return err
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
 }
@@ -0,0 +1,160 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package helm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
+	"k8s.io/helm/pkg/chartutil"
+	helm_env "k8s.io/helm/pkg/helm/environment"
+)
+
+// FileChange is the old and new content of a file.
+type FileChange struct {
+	// Path is the absolute path of the file.
+	Path string
+
+	OldContent,
+	NewContent []byte
+}
+
+// UnifiedDiff returns the changes of the file as unified diff using the given name for the file.
+func (f *FileChange) UnifiedDiff(name string) (string, error) {
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(f.OldContent)),
+		B:        difflib.SplitLines(string(f.NewContent)),
+		FromFile: "a/" + name,
+		ToFile:   "b/" + name,
+		Context:  3,
+	})
+}
+
+// PlanUpdateDependencies computes the changes of updating the dependencies of the given chart in memory without touching the disk.
+// The changes cover the Chart.yaml, if the chart version is incremented by the given IncType, the requirements.yaml and the lock file.
+// Use IncTypes.None to keep the chart version.
+func PlanUpdateDependencies(chartPath string, reqsToUpdate []*Result, incType IncType, helmSettings *helm_env.EnvSettings) ([]*FileChange, error) {
+	c, err := chartutil.Load(chartPath)
+	if err != nil {
+		return nil, err
+	}
+
+	chartFile, err := readFileChange(filepath.Join(chartPath, chartMetadataName))
+	if err != nil {
+		return nil, err
+	}
+
+	if incType != IncTypes.None {
+		chartVersion, err := getChartVersion(c)
+		if err != nil {
+			return nil, err
+		}
+
+		newVersion := incrementVersion(chartVersion, incType)
+		if chartFile.NewContent, err = setChartVersion(chartFile.NewContent, newVersion.String()); err != nil {
+			return nil, errors.Wrapf(err, "error updating %s", chartFile.Path)
+		}
+	}
+
+	// The dependencies of an apiVersion v2 chart are in the Chart.yaml.
+	reqsFile := chartFile
+	if !isAPIVersionV2(c) {
+		if reqsFile, err = readFileChange(filepath.Join(chartPath, requirementsName)); err != nil {
+			return nil, err
+		}
+	}
+
+	depVersions, err := getDependencyVersions(reqsToUpdate)
+	if err != nil {
+		return nil, err
+	}
+
+	if reqsFile.NewContent, err = setDependencyVersions(reqsFile.NewContent, depVersions); err != nil {
+		return nil, errors.Wrapf(err, "error updating %s", reqsFile.Path)
+	}
+
+	reqs := &chartutil.Requirements{}
+	if err := fromYaml(reqsFile.NewContent, reqs); err != nil {
+		return nil, err
+	}
+
+	if err := checkOCIDependencies(c, reqs); err != nil {
+		return nil, err
+	}
+
+	lockFile, err := readFileChange(filepath.Join(chartPath, lockFileName(c)))
+	if err != nil {
+		return nil, err
+	}
+
+	repos, err := loadRepositories(helmSettings)
+	if err != nil {
+		return nil, err
+	}
+
+	lock, err := resolveLock(chartPath, reqs, isAPIVersionV2(c), repos)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error resolving dependencies of chart %s", chartPath)
+	}
+	// The requirements.lock is written by Helm 2, the Chart.lock by syncChartLock.
+	if isAPIVersionV2(c) {
+		lockFile.NewContent, err = toYamlWithIndent(lock, 0)
+	} else {
+		lockFile.NewContent, err = requirementsLockContent(lockFile.OldContent, lock)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []*FileChange{chartFile}
+	if reqsFile != chartFile {
+		changes = append(changes, reqsFile)
+	}
+	return append(changes, lockFile), nil
+}
+
+// getDependencyVersions returns the versions the given dependencies are updated to and, if replaced, their replacements.
+func getDependencyVersions(reqsToUpdate []*Result) ([]dependencyVersion, error) {
+	depVersions := make([]dependencyVersion, 0, len(reqsToUpdate))
+	for _, r := range reqsToUpdate {
+		newVersion, err := r.UpdatedVersion()
+		if err != nil {
+			return nil, errors.Wrapf(err, "error updating version of dependency %s", r.Name)
+		}
+		dv := dependencyVersion{name: r.Name, alias: r.Alias, version: newVersion}
+		if r.Replace {
+			dv.newName, dv.newRepository = r.Replacement.Name, r.Replacement.Repository
+		}
+		depVersions = append(depVersions, dv)
+	}
+	return depVersions, nil
+}
+
+// readFileChange reads the file as starting point for a FileChange. A missing file is treated as empty.
+func readFileChange(path string) (*FileChange, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return &FileChange{Path: path, OldContent: data, NewContent: data}, nil
+}
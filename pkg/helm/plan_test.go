@@ -0,0 +1,121 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package helm
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"testing"
+
+	"github.com/Masterminds/semver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/helm/pkg/chartutil"
+	helm_env "k8s.io/helm/pkg/helm/environment"
+)
+
+// newLocalChart creates an umbrella chart with a local dependency in a temporary directory and returns the path of the umbrella chart.
+func newLocalChart(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "charts")
+	require.NoError(t, err, "there must be no error creating the charts directory")
+
+	files := map[string]string{
+		"sub/Chart.yaml":              "apiVersion: v1\nname: sub\nversion: 0.2.0\n",
+		"umbrella/Chart.yaml":         "apiVersion: v1\nname: umbrella\nversion: 1.0.0\n",
+		"umbrella/requirements.yaml":  "dependencies:\n  - name: sub\n    repository: file://../sub\n    version: 0.1.0\n",
+		"umbrella/requirements.lock":  "dependencies: []\n",
+		"umbrella/values.yaml":        "sub: {}\n",
+		"umbrella/templates/.gitkeep": "",
+	}
+	for name, content := range files {
+		require.NoError(t, os.MkdirAll(path.Dir(path.Join(dir, name)), 0755))
+		require.NoError(t, ioutil.WriteFile(path.Join(dir, name), []byte(content), 0644))
+	}
+	return path.Join(dir, "umbrella")
+}
+
+func TestPlanUpdateDependencies(t *testing.T) {
+	chartPath := newLocalChart(t)
+	defer os.RemoveAll(path.Dir(chartPath))
+
+	reqsToUpdate := []*Result{{
+		Dependency:     &chartutil.Dependency{Name: "sub", Repository: "file://../sub", Version: "0.1.0"},
+		ChartPath:      chartPath,
+		CurrentVersion: semver.MustParse("0.1.0"),
+		LatestVersion:  semver.MustParse("0.2.0"),
+	}}
+
+	changes, err := PlanUpdateDependencies(chartPath, reqsToUpdate, IncTypes.Patch, &helm_env.EnvSettings{Home: GetHelmHome()})
+	require.NoError(t, err, "there should be no error planning the update")
+	require.Len(t, changes, 3, "the Chart.yaml, requirements.yaml and requirements.lock should be changed")
+
+	assert.Contains(t, string(changes[0].NewContent), "version: 1.0.1")
+	assert.Contains(t, string(changes[1].NewContent), "version: 0.2.0")
+	assert.Contains(t, string(changes[2].NewContent), "version: 0.2.0")
+
+	diff, err := changes[1].UnifiedDiff(requirementsName)
+	require.NoError(t, err)
+	assert.Contains(t, diff, "-    version: 0.1.0\n+    version: 0.2.0\n")
+
+	data, err := ioutil.ReadFile(path.Join(chartPath, requirementsName))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "version: 0.1.0", "the requirements.yaml must not be changed on disk")
+}
+
+func TestPlanUpdateDependenciesMatchesUpdate(t *testing.T) {
+	chartPath := newLocalChart(t)
+	defer os.RemoveAll(path.Dir(chartPath))
+
+	helmSettings, cleanup := newHelmSettings(t)
+	defer cleanup()
+	// Helm loads the indices of all configured repositories, which are not needed for the local dependency.
+	require.NoError(t, ioutil.WriteFile(helmSettings.Home.RepositoryFile(), []byte("apiVersion: v1\nrepositories: []\n"), 0644))
+
+	reqsToUpdate := []*Result{{
+		Dependency:     &chartutil.Dependency{Name: "sub", Repository: "file://../sub", Version: "0.1.0"},
+		ChartPath:      chartPath,
+		CurrentVersion: semver.MustParse("0.1.0"),
+		LatestVersion:  semver.MustParse("0.2.0"),
+	}}
+
+	changes, err := PlanUpdateDependencies(chartPath, reqsToUpdate, IncTypes.None, helmSettings)
+	require.NoError(t, err, "there should be no error planning the update")
+	require.Len(t, changes, 3)
+
+	require.NoError(t, UpdateDependencies(chartPath, reqsToUpdate, helmSettings, nil), "there should be no error updating the dependencies")
+
+	for _, change := range changes {
+		data, err := ioutil.ReadFile(change.Path)
+		require.NoError(t, err)
+		assert.Equal(t, withoutGenerated(change.NewContent), withoutGenerated(data), "the planned %s should be the one written by the update", path.Base(change.Path))
+	}
+
+	// Helm does not rewrite a requirements.lock whose digest did not change.
+	changes, err = PlanUpdateDependencies(chartPath, nil, IncTypes.None, helmSettings)
+	require.NoError(t, err)
+	assert.Equal(t, string(changes[2].OldContent), string(changes[2].NewContent), "the requirements.lock should not be changed")
+}
+
+// withoutGenerated removes the timestamp of a lock file, which differs between two writes.
+func withoutGenerated(data []byte) string {
+	return regexp.MustCompile(`(?m)^generated: .*$`).ReplaceAllString(string(data), "generated: <timestamp>")
+}
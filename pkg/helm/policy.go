@@ -0,0 +1,232 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package helm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"github.com/pkg/errors"
+	yamlv3 "gopkg.in/yaml.v3"
+	"k8s.io/helm/pkg/chartutil"
+)
+
+const (
+	policyFileName = ".outdated-dependencies.yaml"
+	dateFormat     = "2006-01-02"
+)
+
+// Policy controls how the dependencies of a chart are updated.
+// It is read from the .outdated-dependencies.yaml next to the Chart.yaml.
+type Policy struct {
+	Dependencies []*DependencyPolicy `json:"dependencies,omitempty" yaml:"dependencies,omitempty"`
+}
+
+// DependencyPolicy controls how a single dependency is updated.
+type DependencyPolicy struct {
+	// Name or alias of the dependency.
+	Name string `json:"name" yaml:"name"`
+
+	// Repository of the dependency. Optional, can also just be a part of the URL.
+	Repository string `json:"repository,omitempty" yaml:"repository,omitempty"`
+
+	// Ignore the dependency entirely.
+	Ignore bool `json:"ignore,omitempty" yaml:"ignore,omitempty"`
+
+	// Pin the dependency to a major (e.g. "8") or minor (e.g. "8.1") line.
+	Pin string `json:"pin,omitempty" yaml:"pin,omitempty"`
+
+	// AllowedUpdateTypes limits updates to the given types (patch, minor, major). All are allowed if empty.
+	AllowedUpdateTypes []IncType `json:"allowedUpdateTypes,omitempty" yaml:"allowedUpdateTypes,omitempty"`
+
+	// BlockedVersions are never updated to.
+	BlockedVersions []string `json:"blockedVersions,omitempty" yaml:"blockedVersions,omitempty"`
+
+	// SnoozeUntil ignores the dependency until the given date (YYYY-MM-DD).
+	SnoozeUntil string `json:"snoozeUntil,omitempty" yaml:"snoozeUntil,omitempty"`
+}
+
+// LoadPolicy loads the policy of the given chart. An empty policy is returned if the chart has none.
+func LoadPolicy(chartPath string) (*Policy, error) {
+	p := &Policy{}
+	data, err := ioutil.ReadFile(filepath.Join(chartPath, policyFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return p, nil
+		}
+		return nil, err
+	}
+
+	// The policy is decoded directly from YAML, so unquoted versions like 8.10 keep their text instead of becoming the number 8.1.
+	if err := yamlv3.Unmarshal(data, p); err != nil {
+		return nil, errors.Wrapf(err, "error parsing %s", policyFileName)
+	}
+
+	for _, dp := range p.Dependencies {
+		if err := dp.validate(); err != nil {
+			return nil, errors.Wrapf(err, "invalid policy for dependency %s", dp.Name)
+		}
+	}
+	return p, nil
+}
+
+// FilterDependencies removes the dependencies, which are ignored or snoozed at the given time.
+func (p *Policy) FilterDependencies(dependencies []*chartutil.Dependency, now time.Time) []*chartutil.Dependency {
+	var filteredDeps []*chartutil.Dependency
+	for _, dep := range dependencies {
+		if dp := p.forDependency(dep); dp != nil && (dp.Ignore || dp.isSnoozed(now)) {
+			continue
+		}
+		filteredDeps = append(filteredDeps, dep)
+	}
+	return filteredDeps
+}
+
+// FilterVersions returns the versions the dependency with the given current version may be updated to.
+func (p *Policy) FilterVersions(dep *chartutil.Dependency, currentVersion *semver.Version, versions semver.Collection) semver.Collection {
+	dp := p.forDependency(dep)
+	if dp == nil {
+		return versions
+	}
+
+	var filteredVersions semver.Collection
+	for _, v := range versions {
+		if dp.allows(currentVersion, v) {
+			filteredVersions = append(filteredVersions, v)
+		}
+	}
+	return filteredVersions
+}
+
+func (p *Policy) forDependency(dep *chartutil.Dependency) *DependencyPolicy {
+	for _, dp := range p.Dependencies {
+		if dp.Name != dep.Name && dp.Name != dep.Alias {
+			continue
+		}
+		if dp.Repository != "" && !stringSliceContains([]string{dp.Repository}, dep.Repository) {
+			continue
+		}
+		return dp
+	}
+	return nil
+}
+
+func (dp *DependencyPolicy) validate() error {
+	if dp.Name == "" {
+		return errors.New("name is required")
+	}
+
+	if _, _, err := dp.pinnedLine(); err != nil {
+		return err
+	}
+
+	for _, t := range dp.AllowedUpdateTypes {
+		if _, err := ParseIncType(string(t)); err != nil {
+			return err
+		}
+	}
+
+	for _, v := range dp.BlockedVersions {
+		if _, err := semver.NewVersion(v); err != nil {
+			return errors.Wrapf(err, "invalid blocked version %s", v)
+		}
+	}
+
+	if dp.SnoozeUntil != "" {
+		if _, err := parseDate(dp.SnoozeUntil); err != nil {
+			return errors.Wrapf(err, "invalid snooze date %s", dp.SnoozeUntil)
+		}
+	}
+	return nil
+}
+
+func (dp *DependencyPolicy) isSnoozed(now time.Time) bool {
+	if dp.SnoozeUntil == "" {
+		return false
+	}
+
+	until, err := parseDate(dp.SnoozeUntil)
+	return err == nil && now.Before(until)
+}
+
+// parseDate parses a date (YYYY-MM-DD). Unquoted dates are converted to timestamps when the YAML is read, so these are accepted as well.
+func parseDate(date string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, date); err == nil {
+		return t, nil
+	}
+	return time.Parse(dateFormat, date)
+}
+
+func (dp *DependencyPolicy) allows(currentVersion, v *semver.Version) bool {
+	for _, blocked := range dp.BlockedVersions {
+		if b, err := semver.NewVersion(blocked); err == nil && b.Equal(v) {
+			return false
+		}
+	}
+
+	if major, minor, err := dp.pinnedLine(); err == nil && major >= 0 {
+		if v.Major() != major || (minor >= 0 && v.Minor() != minor) {
+			return false
+		}
+	}
+
+	if len(dp.AllowedUpdateTypes) == 0 {
+		return true
+	}
+
+	incType := GetIncType(currentVersion, v)
+	if incType == IncTypes.None {
+		return true
+	}
+
+	for _, t := range dp.AllowedUpdateTypes {
+		if t == incType {
+			return true
+		}
+	}
+	return false
+}
+
+// pinnedLine returns the major and minor version the dependency is pinned to or -1 if not pinned.
+func (dp *DependencyPolicy) pinnedLine() (int64, int64, error) {
+	if dp.Pin == "" {
+		return -1, -1, nil
+	}
+
+	segments := strings.Split(strings.TrimPrefix(dp.Pin, "v"), ".")
+	if len(segments) > 2 {
+		return -1, -1, errors.Errorf("pin %s must be a major or minor line like 8 or 8.1", dp.Pin)
+	}
+
+	line := []int64{-1, -1}
+	for idx, s := range segments {
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return -1, -1, errors.Wrapf(err, "invalid pin %s", dp.Pin)
+		}
+		line[idx] = i
+	}
+	return line[0], line[1], nil
+}
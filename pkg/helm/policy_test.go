@@ -0,0 +1,138 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package helm
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/helm/pkg/chartutil"
+)
+
+const policyFile = `dependencies:
+  - name: prometheus-operator
+    pin: "8"
+    blockedVersions:
+      - 8.3.0
+  - name: kube-state-metrics
+    allowedUpdateTypes:
+      - patch
+  - name: grafana
+    ignore: true
+  - name: alertmanager
+    snoozeUntil: 2019-12-01
+`
+
+func loadTestPolicy(t *testing.T) *Policy {
+	chartPath, err := ioutil.TempDir("", "chart")
+	require.NoError(t, err, "there must be no error creating the chart directory")
+	defer os.RemoveAll(chartPath)
+
+	require.NoError(t, ioutil.WriteFile(path.Join(chartPath, policyFileName), []byte(policyFile), 0644), "there must be no error writing the policy")
+
+	p, err := LoadPolicy(chartPath)
+	require.NoError(t, err, "there should be no error loading the policy")
+	return p
+}
+
+func TestPolicyFilterDependencies(t *testing.T) {
+	p := loadTestPolicy(t)
+
+	deps := []*chartutil.Dependency{
+		{Name: "prometheus-operator"},
+		{Name: "grafana"},
+		{Name: "alertmanager"},
+	}
+
+	filtered := p.FilterDependencies(deps, time.Date(2019, 11, 1, 0, 0, 0, 0, time.UTC))
+	require.Len(t, filtered, 1, "ignored and snoozed dependencies should be removed")
+	assert.Equal(t, "prometheus-operator", filtered[0].Name)
+
+	filtered = p.FilterDependencies(deps, time.Date(2019, 12, 2, 0, 0, 0, 0, time.UTC))
+	assert.Len(t, filtered, 2, "the dependency should no longer be snoozed")
+}
+
+func TestPolicyFilterVersions(t *testing.T) {
+	p := loadTestPolicy(t)
+	versions := newVersions("8.2.0", "8.2.1", "8.3.0", "9.0.0")
+
+	filtered := p.FilterVersions(&chartutil.Dependency{Name: "prometheus-operator"}, semver.MustParse("8.2.0"), versions)
+	assert.Equal(t, newVersions("8.2.0", "8.2.1"), filtered, "versions outside the pinned line and blocked versions should be removed")
+
+	filtered = p.FilterVersions(&chartutil.Dependency{Name: "kube-state-metrics"}, semver.MustParse("8.2.0"), versions)
+	assert.Equal(t, newVersions("8.2.0", "8.2.1"), filtered, "only patch updates should be allowed")
+
+	filtered = p.FilterVersions(&chartutil.Dependency{Name: "unknown"}, semver.MustParse("8.2.0"), versions)
+	assert.Equal(t, versions, filtered, "dependencies without policy should not be restricted")
+}
+
+func TestLoadPolicyUnquotedVersions(t *testing.T) {
+	chartPath, err := ioutil.TempDir("", "chart")
+	require.NoError(t, err, "there must be no error creating the chart directory")
+	defer os.RemoveAll(chartPath)
+
+	policy := `dependencies:
+  - name: prometheus-operator
+    pin: 8
+    blockedVersions:
+      - 8.3
+      - 8.2.1
+      - 8.10
+  - name: kube-state-metrics
+    pin: 8.10
+`
+	require.NoError(t, ioutil.WriteFile(path.Join(chartPath, policyFileName), []byte(policy), 0644))
+
+	p, err := LoadPolicy(chartPath)
+	require.NoError(t, err, "there should be no error loading unquoted versions")
+	require.Len(t, p.Dependencies, 2)
+	assert.Equal(t, "8", p.Dependencies[0].Pin)
+	assert.Equal(t, []string{"8.3", "8.2.1", "8.10"}, p.Dependencies[0].BlockedVersions, "the text of unquoted versions should be kept")
+	assert.Equal(t, "8.10", p.Dependencies[1].Pin, "the trailing zero of an unquoted pin should be kept")
+	assert.Equal(t, "2019-12-01", loadTestPolicy(t).Dependencies[3].SnoozeUntil, "the text of an unquoted date should be kept")
+
+	versions := newVersions("8.1.0", "8.1.1", "8.2.1", "8.3.0", "8.4.0", "8.10.0", "8.10.1", "9.0.0")
+	filtered := p.FilterVersions(&chartutil.Dependency{Name: "prometheus-operator"}, semver.MustParse("8.1.0"), versions)
+	assert.Equal(t, newVersions("8.1.0", "8.1.1", "8.4.0", "8.10.1"), filtered)
+
+	filtered = p.FilterVersions(&chartutil.Dependency{Name: "kube-state-metrics"}, semver.MustParse("8.10.0"), versions)
+	assert.Equal(t, newVersions("8.10.0", "8.10.1"), filtered, "the dependency should be pinned to 8.10, not 8.1")
+}
+
+func TestLoadPolicyInvalid(t *testing.T) {
+	chartPath, err := ioutil.TempDir("", "chart")
+	require.NoError(t, err, "there must be no error creating the chart directory")
+	defer os.RemoveAll(chartPath)
+
+	require.NoError(t, ioutil.WriteFile(path.Join(chartPath, policyFileName), []byte("dependencies:\n  - name: foo\n    pin: 1.2.3\n"), 0644))
+
+	_, err = LoadPolicy(chartPath)
+	assert.Error(t, err, "a pin to a patch version should be rejected")
+
+	p, err := LoadPolicy(path.Join(chartPath, "missing"))
+	require.NoError(t, err, "a missing policy should not be an error")
+	assert.Empty(t, p.Dependencies)
+}
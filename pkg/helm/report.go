@@ -0,0 +1,179 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package helm
+
+import (
+	"math"
+	"time"
+)
+
+// ReportSchemaVersion is the version of the Report schema.
+// It is incremented on every incompatible change of the schema.
+const ReportSchemaVersion = "v1"
+
+// Report is the machine-readable representation of the outdated and deprecated dependencies.
+type Report struct {
+	SchemaVersion string              `json:"schemaVersion" yaml:"schemaVersion"`
+	Dependencies  []*ReportDependency `json:"dependencies" yaml:"dependencies"`
+
+	// Charts are the metrics aggregated per chart.
+	Charts []*ReportChart `json:"charts,omitempty" yaml:"charts,omitempty"`
+}
+
+// ReportChart are the metrics of the reported dependencies of a chart.
+type ReportChart struct {
+	ChartPath      string  `json:"chartPath" yaml:"chartPath"`
+	Outdated       int     `json:"outdated" yaml:"outdated"`
+	VersionsBehind int     `json:"versionsBehind" yaml:"versionsBehind"`
+	Libyear        float64 `json:"libyear" yaml:"libyear"`
+}
+
+// ReportDependency is an outdated or deprecated dependency in the Report.
+type ReportDependency struct {
+	Name       string `json:"name" yaml:"name"`
+	Alias      string `json:"alias,omitempty" yaml:"alias,omitempty"`
+	Repository string `json:"repository" yaml:"repository"`
+
+	// RepositoryName and RepositoryURL are set if the repository is configured in Helm's repositories.yaml.
+	RepositoryName string `json:"repositoryName,omitempty" yaml:"repositoryName,omitempty"`
+	RepositoryURL  string `json:"repositoryURL,omitempty" yaml:"repositoryURL,omitempty"`
+
+	// Version is the version as declared by the chart, which might also be a constraint.
+	Version        string  `json:"version" yaml:"version"`
+	CurrentVersion string  `json:"currentVersion" yaml:"currentVersion"`
+	LatestVersion  string  `json:"latestVersion" yaml:"latestVersion"`
+	UpdateType     IncType `json:"updateType" yaml:"updateType"`
+
+	// Risk is the type of update the update is treated like if it differs from the UpdateType, e.g. major if the values violate the schema.
+	Risk IncType `json:"risk,omitempty" yaml:"risk,omitempty"`
+
+	// CurrentAppVersion and LatestAppVersion are the appVersion of the chart in the current and latest version if known.
+	CurrentAppVersion string `json:"currentAppVersion,omitempty" yaml:"currentAppVersion,omitempty"`
+	LatestAppVersion  string `json:"latestAppVersion,omitempty" yaml:"latestAppVersion,omitempty"`
+
+	// CurrentReleaseDate and LatestReleaseDate are the release dates in RFC 3339 format if known.
+	CurrentReleaseDate string `json:"currentReleaseDate,omitempty" yaml:"currentReleaseDate,omitempty"`
+	LatestReleaseDate  string `json:"latestReleaseDate,omitempty" yaml:"latestReleaseDate,omitempty"`
+
+	// VersionsBehind is the number of versions between the current and latest one and Libyear the time between their releases in years.
+	VersionsBehind int     `json:"versionsBehind,omitempty" yaml:"versionsBehind,omitempty"`
+	Libyear        float64 `json:"libyear,omitempty" yaml:"libyear,omitempty"`
+
+	// ChartPath is the path of the chart declaring the dependency relative to the path the report was created for.
+	ChartPath string `json:"chartPath" yaml:"chartPath"`
+
+	// Path of a transitive dependency like "umbrella > prometheus-operator > kube-state-metrics".
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+
+	// StaleIndex is set if the index of the repository could not be downloaded and the cached one was used.
+	StaleIndex bool `json:"staleIndex,omitempty" yaml:"staleIndex,omitempty"`
+
+	// Deprecated is set if the dependency is deprecated. Replacement is its suggested replacement if configured.
+	Deprecated  bool               `json:"deprecated,omitempty" yaml:"deprecated,omitempty"`
+	Replacement *ReportReplacement `json:"replacement,omitempty" yaml:"replacement,omitempty"`
+
+	// Values is the difference between the default values of the current and latest version if analyzed.
+	Values *ReportValues `json:"values,omitempty" yaml:"values,omitempty"`
+}
+
+// ReportValues are the changes of the default values of a dependency in the Report.
+type ReportValues struct {
+	Changes []*ValuesChange `json:"changes" yaml:"changes"`
+
+	// Affected are the values set by the chart, which were removed or renamed.
+	Affected []*ValuesChange `json:"affected,omitempty" yaml:"affected,omitempty"`
+
+	// SchemaChanges are the breaking changes of the values schema and SchemaErrors the violations of the latest one by the values set by the chart.
+	SchemaChanges []*SchemaChange `json:"schemaChanges,omitempty" yaml:"schemaChanges,omitempty"`
+	SchemaErrors  []string        `json:"schemaErrors,omitempty" yaml:"schemaErrors,omitempty"`
+}
+
+// NewReportValues returns the analysis of the values in the Report.
+func NewReportValues(a *ValuesAnalysis) *ReportValues {
+	return &ReportValues{Changes: a.Changes, Affected: a.Affected, SchemaChanges: a.SchemaChanges, SchemaErrors: a.SchemaErrors}
+}
+
+// ReportReplacement is the suggested replacement of a deprecated dependency in the Report.
+type ReportReplacement struct {
+	Name          string `json:"name" yaml:"name"`
+	Repository    string `json:"repository" yaml:"repository"`
+	LatestVersion string `json:"latestVersion,omitempty" yaml:"latestVersion,omitempty"`
+}
+
+// NewReport returns the report for the given results. Chart paths are made relative to the given root path.
+func NewReport(rootPath string, results []*Result) *Report {
+	r := &Report{
+		SchemaVersion: ReportSchemaVersion,
+		Dependencies:  make([]*ReportDependency, 0, len(results)),
+	}
+
+	for _, res := range results {
+		d := &ReportDependency{
+			Name:              res.Name,
+			Alias:             res.Alias,
+			Repository:        res.Repository,
+			RepositoryName:    res.RepositoryName,
+			RepositoryURL:     res.RepositoryURL,
+			Version:           res.Version,
+			CurrentVersion:    res.CurrentVersion.String(),
+			LatestVersion:     res.LatestVersion.String(),
+			UpdateType:        GetIncType(res.CurrentVersion, res.LatestVersion),
+			CurrentAppVersion: res.CurrentAppVersion,
+			LatestAppVersion:  res.LatestAppVersion,
+			VersionsBehind:    res.VersionsBehind,
+			Libyear:           roundLibyear(res.Libyear()),
+			ChartPath:         relativePath(rootPath, res.ChartPath),
+			Path:              res.PathString(),
+			StaleIndex:        res.IndexError != nil,
+			Deprecated:        res.Deprecated,
+		}
+		if res.Replacement != nil {
+			d.Replacement = &ReportReplacement{Name: res.Replacement.Name, Repository: res.Replacement.Repository}
+			if res.Replacement.LatestVersion != nil {
+				d.Replacement.LatestVersion = res.Replacement.LatestVersion.String()
+			}
+		}
+		if risk := res.Risk(); risk != d.UpdateType {
+			d.Risk = risk
+		}
+		if a := res.ValuesAnalysis; a != nil {
+			d.Values = NewReportValues(a)
+		}
+		if res.HasReleaseDates() {
+			d.CurrentReleaseDate, d.LatestReleaseDate = res.CurrentReleaseDate.UTC().Format(time.RFC3339), res.LatestReleaseDate.UTC().Format(time.RFC3339)
+		}
+		r.Dependencies = append(r.Dependencies, d)
+	}
+
+	for _, m := range AggregateByChart(results) {
+		r.Charts = append(r.Charts, &ReportChart{
+			ChartPath:      relativePath(rootPath, m.ChartPath),
+			Outdated:       m.Outdated,
+			VersionsBehind: m.VersionsBehind,
+			Libyear:        roundLibyear(m.Libyear),
+		})
+	}
+	return r
+}
+
+// roundLibyear rounds libyears to two decimals.
+func roundLibyear(libyear float64) float64 {
+	return math.Round(libyear*100) / 100
+}
@@ -0,0 +1,120 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package helm
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/helm/pkg/chartutil"
+)
+
+func TestNewReport(t *testing.T) {
+	report := NewReport("/charts", []*Result{{
+		Dependency:         &chartutil.Dependency{Name: "prometheus-operator", Alias: "po", Repository: "https://repo.evil.corp", Version: "~8.2.0"},
+		ChartPath:          "/charts/monitoring",
+		CurrentVersion:     semver.MustParse("8.2.3"),
+		LatestVersion:      semver.MustParse("9.0.0"),
+		CurrentReleaseDate: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+		LatestReleaseDate:  time.Date(2020, 7, 2, 12, 0, 0, 0, time.UTC),
+		VersionsBehind:     7,
+	}})
+
+	data, err := json.Marshal(report)
+	require.NoError(t, err, "there should be no error marshalling the report")
+	assert.JSONEq(t, `{
+		"schemaVersion": "v1",
+		"dependencies": [{
+			"name": "prometheus-operator",
+			"alias": "po",
+			"repository": "https://repo.evil.corp",
+			"version": "~8.2.0",
+			"currentVersion": "8.2.3",
+			"latestVersion": "9.0.0",
+			"updateType": "major",
+			"currentReleaseDate": "2019-01-01T00:00:00Z",
+			"latestReleaseDate": "2020-07-02T12:00:00Z",
+			"versionsBehind": 7,
+			"libyear": 1.5,
+			"chartPath": "monitoring"
+		}],
+		"charts": [{
+			"chartPath": "monitoring",
+			"outdated": 1,
+			"versionsBehind": 7,
+			"libyear": 1.5
+		}]
+	}`, string(data))
+
+	data, err = json.Marshal(NewReport("/charts", nil))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"schemaVersion": "v1", "dependencies": []}`, string(data), "an empty report should have an empty list of dependencies")
+}
+
+func newTestResults() []*Result {
+	return []*Result{
+		{
+			Dependency:     &chartutil.Dependency{Name: "prometheus-operator", Repository: "https://repo.evil.corp", Version: "8.2.0"},
+			ChartPath:      "/charts/monitoring",
+			File:           "/charts/monitoring/requirements.yaml",
+			Line:           2,
+			CurrentVersion: semver.MustParse("8.2.0"),
+			LatestVersion:  semver.MustParse("9.0.0"),
+		},
+		{
+			Dependency:     &chartutil.Dependency{Name: "grafana", Repository: "https://repo.evil.corp", Version: "1.0.0"},
+			ChartPath:      "/charts/monitoring",
+			File:           "/charts/monitoring/requirements.yaml",
+			Line:           5,
+			CurrentVersion: semver.MustParse("1.0.0"),
+			LatestVersion:  semver.MustParse("1.0.0"),
+		},
+	}
+}
+
+func TestNewJUnitReport(t *testing.T) {
+	report := NewJUnitReport("/charts", newTestResults())
+	assert.Equal(t, 2, report.Tests)
+	assert.Equal(t, 1, report.Failures)
+	require.Len(t, report.TestSuites, 1, "there should be one test suite per chart")
+
+	suite := report.TestSuites[0]
+	assert.Equal(t, "monitoring", suite.Name)
+	require.Len(t, suite.TestCases, 2, "there should be one test case per dependency")
+	assert.NotNil(t, suite.TestCases[0].Failure, "outdated dependencies should fail")
+	assert.Equal(t, "major", suite.TestCases[0].Failure.Type)
+	assert.Nil(t, suite.TestCases[1].Failure, "up-to-date dependencies should pass")
+}
+
+func TestNewSARIFReport(t *testing.T) {
+	report := NewSARIFReport("/charts", newTestResults())
+	require.Len(t, report.Runs, 1)
+	require.Len(t, report.Runs[0].Results, 1, "only outdated dependencies should be reported")
+
+	res := report.Runs[0].Results[0]
+	assert.Equal(t, "error", res.Level, "major updates should be reported as error")
+	require.Len(t, res.Locations, 1)
+	assert.Equal(t, "monitoring/requirements.yaml", res.Locations[0].PhysicalLocation.ArtifactLocation.URI)
+	assert.Equal(t, 2, res.Locations[0].PhysicalLocation.Region.StartLine)
+}
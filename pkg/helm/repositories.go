@@ -0,0 +1,124 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package helm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	helm_env "k8s.io/helm/pkg/helm/environment"
+	"k8s.io/helm/pkg/helm/helmpath"
+	"k8s.io/helm/pkg/repo"
+)
+
+const (
+	repoAliasPrefix     = "@"
+	repoAliasNamePrefix = "alias:"
+)
+
+// repositories are the chart repositories configured in Helm's repositories.yaml along with the credentials and replacements of the plugin configuration.
+type repositories struct {
+	home         helmpath.Home
+	entries      []*repo.Entry
+	auth         []*RepositoryAuth
+	replacements []*ChartReplacement
+}
+
+// loadRepositories loads the repositories.yaml of the given Helm home and the plugin configuration.
+// No repositories are configured if the file does not exist.
+func loadRepositories(helmSettings *helm_env.EnvSettings) (*repositories, error) {
+	cfg, err := loadPluginConfig(helmSettings)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &repositories{home: helmSettings.Home, auth: cfg.Repositories, replacements: cfg.Replacements}
+
+	if _, err := os.Stat(helmSettings.Home.RepositoryFile()); os.IsNotExist(err) {
+		return r, nil
+	}
+
+	// Repository files of old Helm versions are converted, but still returned.
+	f, err := repo.LoadRepositoriesFile(helmSettings.Home.RepositoryFile())
+	if err != nil && err != repo.ErrRepoOutOfDate {
+		return nil, errors.Wrapf(err, "error loading %s", helmSettings.Home.RepositoryFile())
+	}
+
+	r.entries = f.Repositories
+	return r, nil
+}
+
+// isRepoAlias checks whether the repository is referenced by its name like "@stable" or "alias:stable".
+func isRepoAlias(repository string) bool {
+	return strings.HasPrefix(repository, repoAliasPrefix) || strings.HasPrefix(repository, repoAliasNamePrefix)
+}
+
+// lookup returns the configured repository referenced by alias or URL.
+// nil is returned for URLs, which are not configured. Unknown aliases are an error.
+func (r *repositories) lookup(repository string) (*repo.Entry, error) {
+	if isRepoAlias(repository) {
+		name := strings.TrimPrefix(strings.TrimPrefix(repository, repoAliasPrefix), repoAliasNamePrefix)
+		for _, e := range r.entries {
+			if e.Name == name {
+				return e, nil
+			}
+		}
+		return nil, errors.Errorf("repository %s not found in %s", repository, r.home.RepositoryFile())
+	}
+
+	for _, e := range r.entries {
+		if strings.TrimSuffix(e.URL, "/") == strings.TrimSuffix(repository, "/") {
+			return e, nil
+		}
+	}
+	return nil, nil
+}
+
+// entry returns the configured repository referenced by alias or URL.
+// A temporary entry named after the URL is returned for URLs, which are not configured.
+// The cache file of the returned entry is always set and either absolute or relative to the cache directory.
+func (r *repositories) entry(repository string) (*repo.Entry, error) {
+	e, err := r.lookup(repository)
+	if err != nil {
+		return nil, err
+	}
+
+	if e == nil {
+		e = &repo.Entry{Name: normalizeRepoName(repository), URL: repository}
+	} else {
+		entry := *e
+		e = &entry
+	}
+
+	if e.Cache == "" {
+		e.Cache = filepath.Base(r.home.CacheIndex(e.Name))
+	}
+	return e, nil
+}
+
+// cacheIndexFile returns the path of the cached index of the repository entry.
+func (r *repositories) cacheIndexFile(e *repo.Entry) string {
+	if filepath.IsAbs(e.Cache) {
+		return e.Cache
+	}
+	return filepath.Join(r.home.Cache(), e.Cache)
+}
@@ -0,0 +1,97 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package helm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	helm_env "k8s.io/helm/pkg/helm/environment"
+	"k8s.io/helm/pkg/helm/helmpath"
+)
+
+const repositoriesFile = `apiVersion: v1
+repositories:
+- name: stable
+  url: https://kubernetes-charts.storage.googleapis.com/
+  cache: stable-index.yaml
+- name: internal
+  url: https://charts.evil.corp
+  cache: /var/cache/helm/internal-index.yaml
+`
+
+func newHelmSettings(t *testing.T) (*helm_env.EnvSettings, func()) {
+	dir, err := ioutil.TempDir("", "helm-home")
+	require.NoError(t, err)
+
+	home := helmpath.Home(dir)
+	require.NoError(t, os.MkdirAll(home.Repository(), 0755))
+	require.NoError(t, ioutil.WriteFile(home.RepositoryFile(), []byte(repositoriesFile), 0644))
+
+	return &helm_env.EnvSettings{Home: home}, func() { os.RemoveAll(dir) }
+}
+
+func TestRepositoriesLookup(t *testing.T) {
+	helmSettings, cleanup := newHelmSettings(t)
+	defer cleanup()
+
+	repos, err := loadRepositories(helmSettings)
+	require.NoError(t, err, "there should be no error loading the repositories")
+
+	for _, repository := range []string{"@stable", "alias:stable", "https://kubernetes-charts.storage.googleapis.com"} {
+		e, err := repos.lookup(repository)
+		require.NoError(t, err, "there should be no error looking up %s", repository)
+		require.NotNil(t, e, "repository %s should be found", repository)
+		assert.Equal(t, "stable", e.Name)
+	}
+
+	_, err = repos.lookup("@incubator")
+	assert.Error(t, err, "unknown aliases should be an error")
+
+	e, err := repos.lookup("https://repo.evil.corp")
+	assert.NoError(t, err)
+	assert.Nil(t, e, "unknown URLs should not be found")
+
+	e, err = repos.entry("@stable")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(helmSettings.Home.Cache(), "stable-index.yaml"), repos.cacheIndexFile(e), "the configured cache file should be used")
+
+	e, err = repos.entry("alias:internal")
+	require.NoError(t, err)
+	assert.Equal(t, "/var/cache/helm/internal-index.yaml", repos.cacheIndexFile(e), "absolute cache files should be used as they are")
+
+	e, err = repos.entry("https://repo.evil.corp")
+	require.NoError(t, err)
+	assert.Equal(t, helmSettings.Home.CacheIndex("repo-evil-corp"), repos.cacheIndexFile(e), "unknown URLs should be cached by their normalized name")
+}
+
+func TestLoadRepositoriesWithoutFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "helm-home")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	repos, err := loadRepositories(&helm_env.EnvSettings{Home: helmpath.Home(dir)})
+	require.NoError(t, err, "a missing repositories.yaml should not be an error")
+	assert.Empty(t, repos.entries)
+}
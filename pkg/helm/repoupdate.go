@@ -0,0 +1,332 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package helm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/helm/pkg/chartutil"
+	helm_env "k8s.io/helm/pkg/helm/environment"
+	"k8s.io/helm/pkg/repo"
+)
+
+const (
+	validatorsSuffix = ".validators"
+	downloadSuffix   = ".download"
+)
+
+// retryBackoff is the time to wait before the first retry of a failed download. It doubles with every retry.
+var retryBackoff = time.Second
+
+// RepoUpdateOptions control how the indices of the repositories are downloaded.
+type RepoUpdateOptions struct {
+	// Concurrency is the maximum number of indices downloaded at the same time.
+	Concurrency int
+
+	// Timeout of a single download. No timeout if 0.
+	Timeout time.Duration
+
+	// Retries of a failed download.
+	Retries int
+
+	// Offline disables downloads. Only the cached indices are used.
+	Offline bool
+
+	// CacheTTL skips the download if the cached index is younger. The index is always downloaded if 0.
+	CacheTTL time.Duration
+
+	// MaxCacheAge is the age of a used cached index, which triggers a warning. No warning if 0.
+	MaxCacheAge time.Duration
+}
+
+// DefaultRepoUpdateOptions returns the default options for downloading the indices of the repositories.
+func DefaultRepoUpdateOptions() *RepoUpdateOptions {
+	return &RepoUpdateOptions{
+		Concurrency: 4,
+		Timeout:     30 * time.Second,
+		Retries:     2,
+		MaxCacheAge: 24 * time.Hour,
+	}
+}
+
+// parallelRepoUpdate downloads the indices of the repositories of the given dependencies.
+// Downloads are skipped in offline mode or if the cached index is younger than the TTL.
+// The errors of the repositories, which could not be updated, are returned by repository name.
+func parallelRepoUpdate(deps []*chartutil.Dependency, repos *repositories, helmSettings *helm_env.EnvSettings, opts *RepoUpdateOptions) map[string]*IndexError {
+	if opts == nil {
+		opts = DefaultRepoUpdateOptions()
+	}
+
+	var entries []*repo.Entry
+	for _, dep := range deps {
+		// Local charts and OCI registries have no index to download.
+		if strings.Contains(dep.Repository, filePrefix) || isOCIRepository(dep.Repository) {
+			continue
+		}
+
+		e, err := repos.entry(dep.Repository)
+		if err != nil {
+			// Reported when looking up the versions of the dependency.
+			continue
+		}
+
+		if !containsRepoEntry(entries, e) {
+			entries = append(entries, e)
+		}
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mtx       sync.Mutex
+		queue     = make(chan *repo.Entry)
+		errs      = make(map[string]*IndexError)
+		downloads []*repo.Entry
+	)
+	for _, e := range entries {
+		age, isCached := cacheAge(repos.cacheIndexFile(e))
+		switch {
+		case opts.Offline:
+			if !isCached {
+				fmt.Fprintf(os.Stderr, "no cached index of the %q chart repository (%s) in offline mode\n", e.Name, e.URL)
+				errs[e.Name] = &IndexError{Repository: e.Name, URL: e.URL, Err: errors.New("no cached index in offline mode")}
+			}
+		case isCached && opts.CacheTTL > 0 && age < opts.CacheTTL:
+			fmt.Fprintf(os.Stderr, "using the cached index of the %q chart repository (%s old)\n", e.URL, formatAge(age))
+		default:
+			downloads = append(downloads, e)
+		}
+	}
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for e := range queue {
+				if err := downloadIndexFile(e, repos, helmSettings, opts); err != nil {
+					fmt.Fprintf(os.Stderr, "unable to get an update from the %q chart repository (%s):\n\t%s\n", e.Name, e.URL, err)
+					mtx.Lock()
+					errs[e.Name] = &IndexError{Repository: e.Name, URL: e.URL, Err: err}
+					mtx.Unlock()
+				} else {
+					fmt.Fprintf(os.Stderr, "successfully got an update from the %q chart repository\n", e.URL)
+				}
+			}
+		}()
+	}
+
+	for _, e := range downloads {
+		queue <- e
+	}
+	close(queue)
+	wg.Wait()
+
+	// Warn about old cached indices, which were not downloaded.
+	for _, e := range entries {
+		if age, isCached := cacheAge(repos.cacheIndexFile(e)); isCached && opts.MaxCacheAge > 0 && age > opts.MaxCacheAge {
+			fmt.Fprintf(os.Stderr, "warning: the cached index of the %q chart repository is %s old\n", e.URL, formatAge(age))
+		}
+	}
+	return errs
+}
+
+// cacheAge returns the age of the cached file and whether it exists.
+func cacheAge(path string) (time.Duration, bool) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0, false
+	}
+	return time.Since(fi.ModTime()), true
+}
+
+func formatAge(age time.Duration) string {
+	return age.Round(time.Minute).String()
+}
+
+// downloadIndexFile downloads the index of the repository to its cache file.
+// Downloads failing because of timeouts, network errors or server errors are retried with exponential backoff.
+func downloadIndexFile(e *repo.Entry, repos *repositories, helmSettings *helm_env.EnvSettings, opts *RepoUpdateOptions) error {
+	var (
+		err     error
+		backoff = retryBackoff
+	)
+	for attempt := 0; attempt <= opts.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		if err = downloadIndexFileWithTimeout(e, repos, helmSettings, opts.Timeout); err == nil || !isRetryable(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// isRetryable checks whether a failed download might succeed if retried.
+// Responses like 401, 403 or 404 are caused by a misconfiguration and fail again.
+func isRetryable(err error) bool {
+	switch e := errors.Cause(err).(type) {
+	case *statusError:
+		return e.StatusCode >= http.StatusInternalServerError || e.StatusCode == http.StatusTooManyRequests
+	case net.Error:
+		// Includes timeouts.
+		return true
+	}
+	return false
+}
+
+func downloadIndexFileWithTimeout(e *repo.Entry, repos *repositories, helmSettings *helm_env.EnvSettings, timeout time.Duration) error {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	r, err := repo.NewChartRepository(e, repos.getters(helmSettings))
+	if err != nil {
+		return err
+	}
+
+	// Only the getter for http(s) supports timeouts and conditional requests. Getters of plugins are used as they are.
+	g, ok := r.Client.(*authGetter)
+	if !ok {
+		// Relative cache files are written to the cache directory.
+		return r.DownloadIndexFile(helmSettings.Home.Cache())
+	}
+
+	g.ctx = ctx
+	return downloadIndexFileIfModified(g, e, repos.cacheIndexFile(e))
+}
+
+// cacheValidators are the validators of a cached index used for conditional requests.
+// They are stored next to the cached index.
+type cacheValidators struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+// downloadIndexFileIfModified downloads the index of the repository unless the cached one is still up to date.
+func downloadIndexFileIfModified(g *authGetter, e *repo.Entry, cacheFile string) error {
+	u, err := url.Parse(e.URL)
+	if err != nil {
+		return err
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/index.yaml"
+
+	buf, validators, err := g.getIfModified(u.String(), loadCacheValidators(cacheFile))
+	if err != nil {
+		return err
+	}
+
+	// Renew the cached index, so it is considered fresh again by the TTL.
+	if buf == nil {
+		now := time.Now()
+		return os.Chtimes(cacheFile, now, now)
+	}
+
+	// Validate the downloaded index before replacing the cached one.
+	tmpFile := cacheFile + downloadSuffix
+	if err := writeFileAtomic(tmpFile, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	if _, err := repo.LoadIndexFile(tmpFile); err != nil {
+		os.Remove(tmpFile)
+		return errors.Wrap(err, "invalid index")
+	}
+	if err := os.Rename(tmpFile, cacheFile); err != nil {
+		os.Remove(tmpFile)
+		return err
+	}
+
+	return saveCacheValidators(cacheFile, validators)
+}
+
+// loadCacheValidators returns the validators of the cached index or nil if there are none.
+func loadCacheValidators(cacheFile string) *cacheValidators {
+	// Validators without the cached index are useless.
+	if _, err := os.Stat(cacheFile); err != nil {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(cacheFile + validatorsSuffix)
+	if err != nil {
+		return nil
+	}
+
+	v := &cacheValidators{}
+	if err := json.Unmarshal(data, v); err != nil || (v.ETag == "" && v.LastModified == "") {
+		return nil
+	}
+	return v
+}
+
+func saveCacheValidators(cacheFile string, v *cacheValidators) error {
+	if v.ETag == "" && v.LastModified == "" {
+		if err := os.Remove(cacheFile + validatorsSuffix); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(cacheFile+validatorsSuffix, data, 0644)
+}
+
+func containsRepoEntry(entries []*repo.Entry, e *repo.Entry) bool {
+	for _, entry := range entries {
+		if entry.Name == e.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedIndexErrors returns the errors sorted by repository name.
+func sortedIndexErrors(errs map[string]*IndexError) []*IndexError {
+	res := make([]*IndexError, 0, len(errs))
+	for _, e := range errs {
+		res = append(res, e)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Repository < res[j].Repository
+	})
+	return res
+}
@@ -0,0 +1,224 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package helm
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/helm/pkg/chartutil"
+	"k8s.io/helm/pkg/repo"
+)
+
+func TestParallelRepoUpdate(t *testing.T) {
+	defer func(d time.Duration) { retryBackoff = d }(retryBackoff)
+	retryBackoff = time.Millisecond
+
+	var flakyRequests, brokenRequests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/flaky/index.yaml":
+			// Only the first attempt fails.
+			if atomic.AddInt32(&flakyRequests, 1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		case "/slow/index.yaml":
+			time.Sleep(200 * time.Millisecond)
+		case "/broken/index.yaml":
+			atomic.AddInt32(&brokenRequests, 1)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, "apiVersion: v1\nentries: {}\n")
+	}))
+	defer srv.Close()
+
+	helmSettings, cleanup := newHelmSettings(t)
+	defer cleanup()
+	require.NoError(t, os.MkdirAll(helmSettings.Home.Cache(), 0755))
+
+	repos, err := loadRepositories(helmSettings)
+	require.NoError(t, err)
+
+	deps := []*chartutil.Dependency{
+		{Name: "a", Repository: srv.URL + "/flaky"},
+		{Name: "b", Repository: srv.URL + "/slow"},
+		{Name: "c", Repository: srv.URL + "/broken"},
+		{Name: "d", Repository: srv.URL + "/broken"},
+	}
+
+	errs := parallelRepoUpdate(deps, repos, helmSettings, &RepoUpdateOptions{Concurrency: 2, Timeout: 50 * time.Millisecond, Retries: 1})
+	require.Len(t, errs, 2, "the slow and the broken repository should fail")
+
+	flaky, err := repos.entry(srv.URL + "/flaky")
+	require.NoError(t, err)
+	assert.NotContains(t, errs, flaky.Name, "the flaky repository should succeed after a retry")
+	assert.FileExists(t, repos.cacheIndexFile(flaky))
+
+	broken, err := repos.entry(srv.URL + "/broken")
+	require.NoError(t, err)
+	require.Contains(t, errs, broken.Name)
+	assert.Equal(t, srv.URL+"/broken", errs[broken.Name].URL)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&brokenRequests), "the broken repository should be downloaded once and retried once")
+
+	slow, err := repos.entry(srv.URL + "/slow")
+	require.NoError(t, err)
+	assert.Contains(t, errs, slow.Name, "the slow repository should time out")
+}
+
+func TestDownloadIndexFileRetries(t *testing.T) {
+	defer func(d time.Duration) { retryBackoff = d }(retryBackoff)
+	retryBackoff = time.Millisecond
+
+	var requests int32
+	status := int32(http.StatusNotFound)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+	}))
+	defer srv.Close()
+
+	helmSettings, cleanup := newHelmSettings(t)
+	defer cleanup()
+	require.NoError(t, os.MkdirAll(helmSettings.Home.Cache(), 0755))
+
+	repos, err := loadRepositories(helmSettings)
+	require.NoError(t, err)
+	e, err := repos.entry(srv.URL)
+	require.NoError(t, err)
+
+	opts := &RepoUpdateOptions{Retries: 2}
+	for _, s := range []int{http.StatusNotFound, http.StatusUnauthorized, http.StatusForbidden} {
+		atomic.StoreInt32(&status, int32(s))
+		atomic.StoreInt32(&requests, 0)
+		assert.Error(t, downloadIndexFile(e, repos, helmSettings, opts))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&requests), "a download failing with %d should not be retried", s)
+	}
+
+	for _, s := range []int{http.StatusTooManyRequests, http.StatusBadGateway} {
+		atomic.StoreInt32(&status, int32(s))
+		atomic.StoreInt32(&requests, 0)
+		assert.Error(t, downloadIndexFile(e, repos, helmSettings, opts))
+		assert.Equal(t, int32(3), atomic.LoadInt32(&requests), "a download failing with %d should be retried", s)
+	}
+}
+
+func TestParallelRepoUpdateCache(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		fmt.Fprint(w, "apiVersion: v1\nentries: {}\n")
+	}))
+	defer srv.Close()
+
+	helmSettings, cleanup := newHelmSettings(t)
+	defer cleanup()
+	require.NoError(t, os.MkdirAll(helmSettings.Home.Cache(), 0755))
+
+	repos, err := loadRepositories(helmSettings)
+	require.NoError(t, err)
+
+	deps := []*chartutil.Dependency{{Name: "a", Repository: srv.URL}}
+	e, err := repos.entry(srv.URL)
+	require.NoError(t, err)
+
+	errs := parallelRepoUpdate(deps, repos, helmSettings, &RepoUpdateOptions{Offline: true})
+	require.Contains(t, errs, e.Name, "a missing cached index should be an error in offline mode")
+	assert.Equal(t, int32(0), atomic.LoadInt32(&requests), "nothing should be downloaded in offline mode")
+
+	errs = parallelRepoUpdate(deps, repos, helmSettings, &RepoUpdateOptions{CacheTTL: time.Hour})
+	assert.Empty(t, errs)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests), "a missing cached index should be downloaded")
+
+	errs = parallelRepoUpdate(deps, repos, helmSettings, &RepoUpdateOptions{CacheTTL: time.Hour})
+	assert.Empty(t, errs)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests), "a fresh cached index should not be downloaded again")
+
+	errs = parallelRepoUpdate(deps, repos, helmSettings, &RepoUpdateOptions{Offline: true})
+	assert.Empty(t, errs, "the cached index should be used in offline mode")
+
+	old := time.Now().Add(-2 * time.Hour)
+	require.NoError(t, os.Chtimes(repos.cacheIndexFile(e), old, old))
+	errs = parallelRepoUpdate(deps, repos, helmSettings, &RepoUpdateOptions{CacheTTL: time.Hour})
+	assert.Empty(t, errs)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests), "an expired cached index should be downloaded")
+}
+
+func TestDownloadIndexFileIfModified(t *testing.T) {
+	var (
+		etag          atomic.Value
+		fullDownloads int32
+	)
+	etag.Store(`"v1"`)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == etag.Load().(string) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		atomic.AddInt32(&fullDownloads, 1)
+		w.Header().Set("ETag", etag.Load().(string))
+		fmt.Fprintf(w, "apiVersion: v1\nentries: {}\ngenerated: \"2019-10-0%dT00:00:00Z\"\n", atomic.LoadInt32(&fullDownloads))
+	}))
+	defer srv.Close()
+
+	helmSettings, cleanup := newHelmSettings(t)
+	defer cleanup()
+	require.NoError(t, os.MkdirAll(helmSettings.Home.Cache(), 0755))
+
+	repos, err := loadRepositories(helmSettings)
+	require.NoError(t, err)
+	e, err := repos.entry(srv.URL)
+	require.NoError(t, err)
+	cacheFile := repos.cacheIndexFile(e)
+
+	download := func() {
+		g, err := repos.newAuthGetter(e.URL, "", "", "")
+		require.NoError(t, err)
+		require.NoError(t, downloadIndexFileIfModified(g.(*authGetter), e, cacheFile), "there should be no error downloading the index")
+	}
+
+	download()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fullDownloads))
+	assert.Equal(t, &cacheValidators{ETag: `"v1"`}, loadCacheValidators(cacheFile), "the validators should be stored next to the cached index")
+
+	old := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(cacheFile, old, old))
+	download()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fullDownloads), "an unmodified index should not be downloaded again")
+	age, _ := cacheAge(cacheFile)
+	assert.True(t, age < time.Minute, "the cached index should be renewed")
+
+	etag.Store(`"v2"`)
+	download()
+	assert.Equal(t, int32(2), atomic.LoadInt32(&fullDownloads), "a modified index should be downloaded")
+	assert.Equal(t, &cacheValidators{ETag: `"v2"`}, loadCacheValidators(cacheFile))
+
+	i, err := repo.LoadIndexFile(cacheFile)
+	require.NoError(t, err)
+	assert.Equal(t, 2, i.Generated.Day(), "the cached index should be replaced")
+}
@@ -21,6 +21,8 @@ package helm
 
 import (
 	"sort"
+	"strings"
+	"time"
 
 	"github.com/Masterminds/semver"
 	"k8s.io/helm/pkg/chartutil"
@@ -30,13 +32,142 @@ import (
 type Result struct {
 	*chartutil.Dependency
 
+	// ChartPath is the path of the chart declaring the dependency.
+	ChartPath string
+
+	// File is the path of the requirements.yaml or Chart.yaml declaring the dependency and Line the line of the declaration.
+	// Line is 0 if unknown.
+	File string
+	Line int
+
+	// CurrentVersion is the version of the dependency or, if a constraint is used, the newest version satisfying it.
 	CurrentVersion,
 	LatestVersion *semver.Version
+
+	// CurrentAppVersion and LatestAppVersion are the appVersion of the chart in the current and the latest version.
+	// They are empty if unknown, e.g. for charts in OCI registries.
+	CurrentAppVersion,
+	LatestAppVersion string
+
+	// CurrentReleaseDate and LatestReleaseDate are the dates the current and the latest version were released. They are zero if unknown.
+	CurrentReleaseDate,
+	LatestReleaseDate time.Time
+
+	// VersionsBehind is the number of released versions newer than the current one up to the latest one.
+	VersionsBehind int
+
+	// Constraint is set if the version of the dependency is a constraint like "~1.2.0" instead of an exact version.
+	Constraint *semver.Constraints
+
+	// RepositoryName and RepositoryURL are set if the repository is configured in Helm's repositories.yaml.
+	RepositoryName,
+	RepositoryURL string
+
+	// Path are the names of the charts from the root chart to the dependency of a subchart, e.g. [umbrella prometheus-operator kube-state-metrics].
+	// It is only set for transitive dependencies.
+	Path []string
+
+	// IndexError is set if the index of the repository could not be downloaded and the dependency was evaluated against the stale cached index.
+	IndexError *IndexError
+
+	// Deprecated is set if the latest release of the dependency is marked deprecated in its repository or a replacement is configured.
+	Deprecated bool
+
+	// Replacement is the suggested replacement of a deprecated dependency if configured.
+	Replacement *Replacement
+
+	// Replace replaces the dependency by its Replacement when updating the chart.
+	Replace bool
+
+	// ValuesAnalysis is the difference between the default values of the current and latest version. It is only set if analyzed.
+	ValuesAnalysis *ValuesAnalysis
+}
+
+// UpdatedVersion returns the version the dependency is updated to.
+// Constraints are rewritten to include the latest version, keeping their operators.
+// Replaced dependencies are updated to the latest version of their replacement.
+func (r *Result) UpdatedVersion() (string, error) {
+	if r.Replace {
+		return r.Replacement.LatestVersion.String(), nil
+	}
+	if r.Constraint == nil {
+		return r.LatestVersion.String(), nil
+	}
+	return updateConstraint(r.Version, r.LatestVersion)
+}
+
+// displayName returns the path of a transitive dependency or the alias of the dependency or, if not set, its name.
+func (r *Result) displayName() string {
+	if len(r.Path) > 0 {
+		return r.PathString()
+	}
+	if r.Alias != "" {
+		return r.Alias
+	}
+	return r.Name
+}
+
+// PathString returns the path of a transitive dependency like "umbrella > prometheus-operator > kube-state-metrics" or an empty string.
+func (r *Result) PathString() string {
+	return strings.Join(r.Path, " > ")
+}
+
+// IsOutdated checks whether a newer version of the dependency is available.
+func (r *Result) IsOutdated() bool {
+	return r.CurrentVersion.LessThan(r.LatestVersion)
+}
+
+// FilterOutdated returns only the results of outdated dependencies.
+func FilterOutdated(results []*Result) []*Result {
+	var outdated []*Result
+	for _, r := range results {
+		if r.IsOutdated() {
+			outdated = append(outdated, r)
+		}
+	}
+	return outdated
+}
+
+// GroupResultsByChart groups the results by the chart they belong to.
+// The returned chart paths preserve the order of the results.
+func GroupResultsByChart(results []*Result) ([]string, map[string][]*Result) {
+	var (
+		chartPaths []string
+		grouped    = make(map[string][]*Result)
+	)
+	for _, r := range results {
+		if _, ok := grouped[r.ChartPath]; !ok {
+			chartPaths = append(chartPaths, r.ChartPath)
+		}
+		grouped[r.ChartPath] = append(grouped[r.ChartPath], r)
+	}
+	return chartPaths, grouped
 }
 
 func sortResultsAlphabetically(res []*Result) []*Result {
-	sort.Slice(res, func(i, j int) bool {
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].ChartPath != res[j].ChartPath {
+			return res[i].ChartPath < res[j].ChartPath
+		}
+		// Direct dependencies come before transitive ones.
+		if res[i].PathString() != res[j].PathString() {
+			return res[i].PathString() < res[j].PathString()
+		}
 		return res[i].Name < res[j].Name
 	})
 	return res
 }
+
+// IsAppVersionChanged checks whether the appVersion of the latest version differs from the current one.
+func (r *Result) IsAppVersionChanged() bool {
+	return r.LatestAppVersion != "" && r.CurrentAppVersion != r.LatestAppVersion
+}
+
+// Risk returns the type of update the update of the dependency is treated like.
+// Replacing the dependency or values violating the values schema of the latest version are treated like a major update.
+func (r *Result) Risk() IncType {
+	if r.Replace || (r.ValuesAnalysis != nil && r.ValuesAnalysis.IsSchemaInvalid()) {
+		return IncTypes.Major
+	}
+	return GetIncType(r.CurrentVersion, r.LatestVersion)
+}
@@ -0,0 +1,169 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package helm
+
+import "fmt"
+
+const (
+	sarifVersion          = "2.1.0"
+	sarifSchema           = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifRuleOutdated     = "outdated-dependency"
+	sarifRuleDeprecated   = "deprecated-dependency"
+	sarifToolName         = "helm-outdated-dependencies"
+	sarifToolInformation  = "https://github.com/sapcc/helm-outdated-dependencies"
+	sarifSourceRootBaseID = "%SRCROOT%"
+)
+
+// SARIFLog is the root object of a SARIF report.
+// Only the subset of the SARIF 2.1.0 specification needed for reporting outdated dependencies is implemented.
+type SARIFLog struct {
+	Schema  string      `json:"$schema"`
+	Version string      `json:"version"`
+	Runs    []*SARIFRun `json:"runs"`
+}
+
+// SARIFRun is a single run of the tool.
+type SARIFRun struct {
+	Tool    SARIFTool      `json:"tool"`
+	Results []*SARIFResult `json:"results"`
+}
+
+// SARIFTool describes the tool and its rules.
+type SARIFTool struct {
+	Driver SARIFDriver `json:"driver"`
+}
+
+// SARIFDriver describes the tool and its rules.
+type SARIFDriver struct {
+	Name           string       `json:"name"`
+	InformationURI string       `json:"informationUri"`
+	Rules          []*SARIFRule `json:"rules"`
+}
+
+// SARIFRule describes a kind of finding.
+type SARIFRule struct {
+	ID               string       `json:"id"`
+	ShortDescription SARIFMessage `json:"shortDescription"`
+	FullDescription  SARIFMessage `json:"fullDescription"`
+}
+
+// SARIFResult is a single finding.
+type SARIFResult struct {
+	RuleID    string           `json:"ruleId"`
+	Level     string           `json:"level"`
+	Message   SARIFMessage     `json:"message"`
+	Locations []*SARIFLocation `json:"locations"`
+}
+
+// SARIFMessage is a text message.
+type SARIFMessage struct {
+	Text string `json:"text"`
+}
+
+// SARIFLocation points to the declaration of a dependency.
+type SARIFLocation struct {
+	PhysicalLocation SARIFPhysicalLocation `json:"physicalLocation"`
+}
+
+// SARIFPhysicalLocation points to a region in a file.
+type SARIFPhysicalLocation struct {
+	ArtifactLocation SARIFArtifactLocation `json:"artifactLocation"`
+	Region           *SARIFRegion          `json:"region,omitempty"`
+}
+
+// SARIFArtifactLocation is the location of a file.
+type SARIFArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId"`
+}
+
+// SARIFRegion is a region in a file.
+type SARIFRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// NewSARIFReport returns a SARIF report with one result per outdated and per deprecated dependency pointing at its declaration.
+// Major updates are reported as errors, minor ones as warnings and patches as notes. Deprecations are reported as warnings. Paths are made relative to the given root path.
+func NewSARIFReport(rootPath string, results []*Result) *SARIFLog {
+	run := &SARIFRun{
+		Tool: SARIFTool{
+			Driver: SARIFDriver{
+				Name:           sarifToolName,
+				InformationURI: sarifToolInformation,
+				Rules: []*SARIFRule{{
+					ID:               sarifRuleOutdated,
+					ShortDescription: SARIFMessage{Text: "Outdated Helm chart dependency"},
+					FullDescription:  SARIFMessage{Text: "A newer version of the Helm chart dependency is available in its repository."},
+				}, {
+					ID:               sarifRuleDeprecated,
+					ShortDescription: SARIFMessage{Text: "Deprecated Helm chart dependency"},
+					FullDescription:  SARIFMessage{Text: "The Helm chart dependency is deprecated and should be replaced."},
+				}},
+			},
+		},
+		Results: make([]*SARIFResult, 0, len(results)),
+	}
+
+	for _, r := range FilterOutdatedOrDeprecated(results) {
+		location := &SARIFLocation{
+			PhysicalLocation: SARIFPhysicalLocation{
+				ArtifactLocation: SARIFArtifactLocation{URI: relativePath(rootPath, r.File), URIBaseID: sarifSourceRootBaseID},
+			},
+		}
+		if r.Line > 0 {
+			location.PhysicalLocation.Region = &SARIFRegion{StartLine: r.Line}
+		}
+
+		if r.IsOutdated() {
+			incType := GetIncType(r.CurrentVersion, r.LatestVersion)
+			run.Results = append(run.Results, &SARIFResult{
+				RuleID:    sarifRuleOutdated,
+				Level:     sarifLevel(incType),
+				Message:   SARIFMessage{Text: fmt.Sprintf("Dependency %s is outdated: %s -> %s (%s update)", r.displayName(), r.Version, r.LatestVersion.String(), incType)},
+				Locations: []*SARIFLocation{location},
+			})
+		}
+
+		if r.Deprecated {
+			run.Results = append(run.Results, &SARIFResult{
+				RuleID:    sarifRuleDeprecated,
+				Level:     "warning",
+				Message:   SARIFMessage{Text: deprecationMessage(r)},
+				Locations: []*SARIFLocation{location},
+			})
+		}
+	}
+
+	return &SARIFLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs:    []*SARIFRun{run},
+	}
+}
+
+func sarifLevel(incType IncType) string {
+	switch incType {
+	case IncTypes.Major:
+		return "error"
+	case IncTypes.Minor:
+		return "warning"
+	}
+	return "note"
+}
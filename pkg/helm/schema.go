@@ -0,0 +1,298 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package helm
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/helm/pkg/proto/hapi/chart"
+)
+
+const valuesSchemaName = "values.schema.json"
+
+// SchemaChangeType is one of SchemaChangeTypes.
+type SchemaChangeType string
+
+// SchemaChangeTypes enumerates the breaking changes of a values schema.
+var SchemaChangeTypes = struct {
+	Required,
+	Removed,
+	Type,
+	Enum SchemaChangeType
+}{
+	"required",
+	"removed",
+	"type",
+	"enum",
+}
+
+// SchemaChange is a breaking change of the values schema of a dependency between its current and latest version.
+type SchemaChange struct {
+	// Key is the path of the property like "image.tag".
+	Key  string           `json:"key" yaml:"key"`
+	Type SchemaChangeType `json:"type" yaml:"type"`
+
+	// Allowed are the types or enum values allowed by the latest schema if narrowed.
+	Allowed []string `json:"allowed,omitempty" yaml:"allowed,omitempty"`
+}
+
+// String returns the change like "image.tag is required" or "image.pullPolicy narrowed to enum Always, IfNotPresent".
+func (c *SchemaChange) String() string {
+	switch c.Type {
+	case SchemaChangeTypes.Required:
+		return fmt.Sprintf("%s is required", c.Key)
+	case SchemaChangeTypes.Removed:
+		return fmt.Sprintf("%s removed", c.Key)
+	}
+	return fmt.Sprintf("%s narrowed to %s %s", c.Key, c.Type, strings.Join(c.Allowed, ", "))
+}
+
+// valuesSchema is the subset of JSON schema relevant for comparing and validating values:
+// type, enum, required, properties, additionalProperties and items. Other keywords are ignored.
+type valuesSchema struct {
+	Type                 schemaTypes              `json:"type,omitempty"`
+	Enum                 []interface{}            `json:"enum,omitempty"`
+	Required             []string                 `json:"required,omitempty"`
+	Properties           map[string]*valuesSchema `json:"properties,omitempty"`
+	AdditionalProperties interface{}              `json:"additionalProperties,omitempty"`
+	Items                *valuesSchema            `json:"items,omitempty"`
+}
+
+// schemaTypes are the types of a schema, which are given either as string or as list.
+type schemaTypes []string
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (t *schemaTypes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*t = schemaTypes{s}
+		return nil
+	}
+	var l []string
+	if err := json.Unmarshal(data, &l); err != nil {
+		return err
+	}
+	*t = l
+	return nil
+}
+
+// allows checks whether the type is allowed. No types allow any type. Numbers allow integers.
+func (t schemaTypes) allows(typ string) bool {
+	if len(t) == 0 {
+		return true
+	}
+	for _, s := range t {
+		if s == typ || (s == "number" && typ == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// loadValuesSchema returns the values.schema.json of the chart or nil if it has none.
+func loadValuesSchema(c *chart.Chart) (*valuesSchema, error) {
+	for _, f := range c.GetFiles() {
+		if f.GetTypeUrl() != valuesSchemaName {
+			continue
+		}
+		s := &valuesSchema{}
+		if err := json.Unmarshal(f.GetValue(), s); err != nil {
+			return nil, errors.Wrapf(err, "error parsing %s of chart %s", valuesSchemaName, c.GetMetadata().GetName())
+		}
+		return s, nil
+	}
+	return nil, nil
+}
+
+// diffSchemas returns the breaking changes between the current and latest schema sorted by key.
+func diffSchemas(current, latest *valuesSchema) []*SchemaChange {
+	var res []*SchemaChange
+	var diff func(key string, c, l *valuesSchema)
+	diff = func(key string, c, l *valuesSchema) {
+		for _, name := range l.Required {
+			if !containsString(c.Required, name) {
+				res = append(res, &SchemaChange{Key: joinKey(key, name), Type: SchemaChangeTypes.Required})
+			}
+		}
+
+		if len(l.Type) > 0 {
+			for _, t := range typesOf(c.Type) {
+				if !l.Type.allows(t) {
+					res = append(res, &SchemaChange{Key: key, Type: SchemaChangeTypes.Type, Allowed: l.Type})
+					break
+				}
+			}
+		}
+
+		if len(l.Enum) > 0 && !enumContainsAll(l.Enum, c.Enum) {
+			allowed := make([]string, len(l.Enum))
+			for i, v := range l.Enum {
+				allowed[i] = fmt.Sprintf("%v", v)
+			}
+			res = append(res, &SchemaChange{Key: key, Type: SchemaChangeTypes.Enum, Allowed: allowed})
+		}
+
+		for name, cp := range c.Properties {
+			lp, ok := l.Properties[name]
+			if !ok {
+				res = append(res, &SchemaChange{Key: joinKey(key, name), Type: SchemaChangeTypes.Removed})
+				continue
+			}
+			diff(joinKey(key, name), cp, lp)
+		}
+
+		if c.Items != nil && l.Items != nil {
+			diff(key+"[]", c.Items, l.Items)
+		}
+	}
+	diff("", current, latest)
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Key < res[j].Key
+	})
+	return res
+}
+
+// typesOf returns the given types or all types if none are given.
+func typesOf(t schemaTypes) []string {
+	if len(t) == 0 {
+		return []string{"array", "boolean", "integer", "null", "number", "object", "string"}
+	}
+	return t
+}
+
+// enumContainsAll checks whether the enum contains all given values. No values means any value.
+func enumContainsAll(enum, values []interface{}) bool {
+	if len(values) == 0 {
+		return false
+	}
+	for _, v := range values {
+		if !enumContains(enum, v) {
+			return false
+		}
+	}
+	return true
+}
+
+func enumContains(enum []interface{}, v interface{}) bool {
+	for _, e := range enum {
+		if reflect.DeepEqual(e, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// isAdditionalPropertiesAllowed checks whether properties not declared by the schema are allowed.
+func isAdditionalPropertiesAllowed(s *valuesSchema) bool {
+	allowed, ok := s.AdditionalProperties.(bool)
+	return !ok || allowed
+}
+
+// validate validates the values against the schema and returns the violations sorted by key.
+func (s *valuesSchema) validate(values map[string]interface{}) []string {
+	var res []string
+	var validate func(key string, s *valuesSchema, v interface{})
+	validate = func(key string, s *valuesSchema, v interface{}) {
+		displayKey := key
+		if displayKey == "" {
+			displayKey = "values"
+		}
+
+		if t := jsonType(v); !s.Type.allows(t) {
+			res = append(res, fmt.Sprintf("%s: expected %s, got %s", displayKey, strings.Join(s.Type, " or "), t))
+			return
+		}
+		if len(s.Enum) > 0 && !enumContains(s.Enum, v) {
+			res = append(res, fmt.Sprintf("%s: %v is not one of the allowed values", displayKey, v))
+		}
+
+		switch val := v.(type) {
+		case map[string]interface{}:
+			for _, name := range s.Required {
+				if _, ok := val[name]; !ok {
+					res = append(res, fmt.Sprintf("%s: required value missing", joinKey(key, name)))
+				}
+			}
+			for name, pv := range val {
+				if ps, ok := s.Properties[name]; ok {
+					validate(joinKey(key, name), ps, pv)
+				} else if !isAdditionalPropertiesAllowed(s) {
+					res = append(res, fmt.Sprintf("%s: additional value not allowed", joinKey(key, name)))
+				}
+			}
+		case []interface{}:
+			if s.Items != nil {
+				for i, item := range val {
+					validate(fmt.Sprintf("%s[%d]", key, i), s.Items, item)
+				}
+			}
+		}
+	}
+	validate("", s, values)
+
+	sort.Strings(res)
+	return res
+}
+
+// jsonType returns the JSON schema type of the value.
+func jsonType(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case float64:
+		if val == math.Trunc(val) {
+			return "integer"
+		}
+		return "number"
+	}
+	return "unknown"
+}
+
+// joinKey returns the path of the property in the given parent.
+func joinKey(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
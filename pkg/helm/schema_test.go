@@ -0,0 +1,97 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package helm
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/helm/pkg/chartutil"
+)
+
+func parseTestSchema(t *testing.T, data string) *valuesSchema {
+	s := &valuesSchema{}
+	require.NoError(t, json.Unmarshal([]byte(data), s), "there should be no error parsing the schema")
+	return s
+}
+
+func TestDiffSchemas(t *testing.T) {
+	current := parseTestSchema(t, `{
+  "type": "object",
+  "required": ["image"],
+  "properties": {
+    "image": {"type": "object", "properties": {"tag": {"type": "string"}, "pullPolicy": {"type": "string", "enum": ["Always", "IfNotPresent", "Never"]}}},
+    "legacy": {"type": "boolean"},
+    "replicas": {"type": "integer"},
+    "ports": {"type": "array", "items": {"type": ["integer", "string"]}}
+  }
+}`)
+	latest := parseTestSchema(t, `{
+  "type": "object",
+  "required": ["image", "ingress"],
+  "properties": {
+    "image": {"type": "object", "required": ["tag"], "properties": {"tag": {"type": "string"}, "pullPolicy": {"type": "string", "enum": ["Always", "IfNotPresent"]}}},
+    "ingress": {"type": "object"},
+    "replicas": {"type": "number"},
+    "ports": {"type": "array", "items": {"type": "integer"}}
+  }
+}`)
+
+	changes := diffSchemas(current, latest)
+	assert.Equal(t, []*SchemaChange{
+		{Key: "image.pullPolicy", Type: SchemaChangeTypes.Enum, Allowed: []string{"Always", "IfNotPresent"}},
+		{Key: "image.tag", Type: SchemaChangeTypes.Required},
+		{Key: "ingress", Type: SchemaChangeTypes.Required},
+		{Key: "legacy", Type: SchemaChangeTypes.Removed},
+		{Key: "ports[]", Type: SchemaChangeTypes.Type, Allowed: []string{"integer"}},
+	}, changes, "widening integer to number should not be a breaking change")
+	assert.Equal(t, "image.pullPolicy narrowed to enum Always, IfNotPresent", changes[0].String())
+
+	assert.Empty(t, diffSchemas(latest, latest))
+}
+
+func TestValidateValuesSchema(t *testing.T) {
+	s := parseTestSchema(t, `{
+  "type": "object",
+  "required": ["image"],
+  "additionalProperties": false,
+  "properties": {
+    "image": {"type": "object", "required": ["tag"], "properties": {"tag": {"type": "string"}, "pullPolicy": {"enum": ["Always", "IfNotPresent"]}}},
+    "replicas": {"type": "integer"},
+    "ports": {"type": "array", "items": {"type": "integer"}}
+  }
+}`)
+
+	values, err := chartutil.ReadValues([]byte("image:\n  tag: \"1.0\"\nreplicas: 3\nports: [80, 443]\n"))
+	require.NoError(t, err)
+	assert.Empty(t, s.validate(values))
+
+	values, err = chartutil.ReadValues([]byte("image:\n  pullPolicy: Sometimes\nreplicas: 1.5\nports: [80, http]\nextra: true\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"extra: additional value not allowed",
+		"image.pullPolicy: Sometimes is not one of the allowed values",
+		"image.tag: required value missing",
+		"ports[1]: expected integer, got string",
+		"replicas: expected integer, got number",
+	}, s.validate(values))
+}
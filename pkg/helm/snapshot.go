@@ -0,0 +1,148 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package helm
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+const chartsDirName = "charts"
+
+// snapshotNames are the files and folders of a chart, which are modified by an update.
+var snapshotNames = []string{chartMetadataName, requirementsName, requirementsLockName, chartLockName, chartsDirName}
+
+// chartSnapshot is a copy of the files and folders of a chart, which are modified by an update.
+type chartSnapshot struct {
+	chartPath,
+	backupPath string
+
+	// existed contains the names of the files and folders, which existed when the snapshot was taken.
+	existed map[string]bool
+}
+
+// newChartSnapshot copies the files and folders of the chart, which are modified by an update, to a backup folder next to the chart.
+func newChartSnapshot(chartPath string) (*chartSnapshot, error) {
+	backupPath, err := ioutil.TempDir(filepath.Dir(chartPath), "."+filepath.Base(chartPath)+".backup")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &chartSnapshot{
+		chartPath:  chartPath,
+		backupPath: backupPath,
+		existed:    make(map[string]bool, len(snapshotNames)),
+	}
+
+	for _, name := range snapshotNames {
+		src := filepath.Join(chartPath, name)
+		if _, err := os.Lstat(src); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			s.discard()
+			return nil, err
+		}
+
+		if err := copyPath(src, filepath.Join(backupPath, name)); err != nil {
+			s.discard()
+			return nil, errors.Wrapf(err, "error creating snapshot of %s", src)
+		}
+		s.existed[name] = true
+	}
+
+	return s, nil
+}
+
+// restore resets the chart to the state of the snapshot.
+// Files and folders, which did not exist when the snapshot was taken, are removed.
+func (s *chartSnapshot) restore() error {
+	for _, name := range snapshotNames {
+		path := filepath.Join(s.chartPath, name)
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+
+		if !s.existed[name] {
+			continue
+		}
+
+		if err := os.Rename(filepath.Join(s.backupPath, name), path); err != nil {
+			return errors.Wrapf(err, "error restoring %s", path)
+		}
+	}
+	return nil
+}
+
+// discard removes the backup.
+func (s *chartSnapshot) discard() error {
+	return os.RemoveAll(s.backupPath)
+}
+
+// copyPath copies a file or folder recursively.
+func copyPath(src, dst string) error {
+	fi, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+
+	if !fi.IsDir() {
+		return copyFile(src, dst, fi.Mode().Perm())
+	}
+
+	if err := os.MkdirAll(dst, fi.Mode().Perm()); err != nil {
+		return err
+	}
+
+	infos, err := ioutil.ReadDir(src)
+	if err != nil {
+		return err
+	}
+
+	for _, info := range infos {
+		if err := copyPath(filepath.Join(src, info.Name()), filepath.Join(dst, info.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
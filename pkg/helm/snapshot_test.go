@@ -0,0 +1,84 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package helm
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/Masterminds/semver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/helm/pkg/chartutil"
+	helm_env "k8s.io/helm/pkg/helm/environment"
+)
+
+func TestChartSnapshotRestore(t *testing.T) {
+	chartPath := newLocalChart(t)
+	defer os.RemoveAll(path.Dir(chartPath))
+
+	require.NoError(t, os.MkdirAll(path.Join(chartPath, chartsDirName), 0755))
+	require.NoError(t, ioutil.WriteFile(path.Join(chartPath, chartsDirName, "sub-0.1.0.tgz"), []byte("archive"), 0644))
+
+	s, err := newChartSnapshot(chartPath)
+	require.NoError(t, err, "there should be no error creating the snapshot")
+	defer s.discard()
+
+	require.NoError(t, ioutil.WriteFile(path.Join(chartPath, chartMetadataName), []byte("broken"), 0644))
+	require.NoError(t, os.RemoveAll(path.Join(chartPath, chartsDirName)))
+	require.NoError(t, ioutil.WriteFile(path.Join(chartPath, chartLockName), []byte("new"), 0644))
+
+	require.NoError(t, s.restore(), "there should be no error restoring the snapshot")
+
+	data, err := ioutil.ReadFile(path.Join(chartPath, chartMetadataName))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "version: 1.0.0", "the Chart.yaml should be restored")
+
+	_, err = os.Stat(path.Join(chartPath, chartsDirName, "sub-0.1.0.tgz"))
+	assert.NoError(t, err, "the charts folder should be restored")
+
+	_, err = os.Stat(path.Join(chartPath, chartLockName))
+	assert.True(t, os.IsNotExist(err), "files created after the snapshot should be removed")
+}
+
+func TestUpdateChartRollback(t *testing.T) {
+	chartPath := newLocalChart(t)
+	defer os.RemoveAll(path.Dir(chartPath))
+
+	reqsToUpdate := []*Result{{
+		Dependency:     &chartutil.Dependency{Name: "unknown", Repository: "file://../unknown", Version: "0.1.0"},
+		ChartPath:      chartPath,
+		CurrentVersion: semver.MustParse("0.1.0"),
+		LatestVersion:  semver.MustParse("0.2.0"),
+	}}
+
+	err := UpdateChart(chartPath, reqsToUpdate, IncTypes.Patch, &helm_env.EnvSettings{Home: GetHelmHome()}, nil)
+	require.Error(t, err, "updating an unknown dependency should fail")
+
+	data, err := ioutil.ReadFile(path.Join(chartPath, chartMetadataName))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "version: 1.0.0", "the chart version must not be incremented after a failed update")
+
+	infos, err := ioutil.ReadDir(path.Dir(chartPath))
+	require.NoError(t, err)
+	assert.Len(t, infos, 2, "the backup should be removed")
+}
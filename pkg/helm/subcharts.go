@@ -0,0 +1,101 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package helm
+
+import (
+	"strings"
+
+	"k8s.io/helm/pkg/chartutil"
+	"k8s.io/helm/pkg/proto/hapi/chart"
+)
+
+// transitiveDependency is a dependency of a subchart.
+type transitiveDependency struct {
+	dep *chartutil.Dependency
+
+	// path are the names of the charts from the root chart to the dependency, e.g. [umbrella prometheus-operator kube-state-metrics].
+	path []string
+}
+
+// loadTransitiveDependencies returns the dependencies of the subcharts in the charts/ folder of the given chart at every level.
+// Subcharts may be packaged or unpacked. Local dependencies of subcharts are skipped as they cannot be looked up.
+func loadTransitiveDependencies(chartPath string, f *Filter) ([]*transitiveDependency, error) {
+	c, err := chartutil.Load(chartPath)
+	if err != nil {
+		return nil, err
+	}
+	return subchartDependencies(c, []string{c.GetMetadata().GetName()}, f), nil
+}
+
+func subchartDependencies(c *chart.Chart, path []string, f *Filter) []*transitiveDependency {
+	var res []*transitiveDependency
+	for _, sc := range c.GetDependencies() {
+		scPath := appendPath(path, sc.GetMetadata().GetName())
+
+		for _, dep := range f.FilterDependencies(declaredDependencies(sc)) {
+			if !strings.HasPrefix(dep.Repository, filePrefix) {
+				res = append(res, &transitiveDependency{dep: dep, path: appendPath(scPath, dep.Name)})
+			}
+		}
+		res = append(res, subchartDependencies(sc, scPath, f)...)
+	}
+	return res
+}
+
+// declaredDependencies returns the dependencies of a subchart.
+// Helm 2 does not load the dependencies from the Chart.yaml, so they are taken from the Chart.lock for apiVersion v2 charts.
+// The version of a dependency is the one of the chart vendored in the charts/ folder of the subchart if any.
+func declaredDependencies(c *chart.Chart) []*chartutil.Dependency {
+	var deps []*chartutil.Dependency
+	if reqs, err := chartutil.LoadRequirements(c); err == nil {
+		deps = reqs.Dependencies
+	} else {
+		for _, f := range c.GetFiles() {
+			if f.GetTypeUrl() != chartLockName {
+				continue
+			}
+			lock := &chartutil.RequirementsLock{}
+			if err := fromYaml(f.GetValue(), lock); err == nil {
+				deps = lock.Dependencies
+			}
+		}
+	}
+
+	vendoredVersions := make(map[string]string)
+	for _, sc := range c.GetDependencies() {
+		vendoredVersions[sc.GetMetadata().GetName()] = sc.GetMetadata().GetVersion()
+	}
+
+	res := make([]*chartutil.Dependency, 0, len(deps))
+	for _, dep := range deps {
+		d := *dep
+		if v, ok := vendoredVersions[d.Name]; ok && v != "" {
+			d.Version = v
+		}
+		res = append(res, &d)
+	}
+	return res
+}
+
+func appendPath(path []string, name string) []string {
+	res := make([]string, len(path), len(path)+1)
+	copy(res, path)
+	return append(res, name)
+}
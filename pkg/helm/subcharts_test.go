@@ -0,0 +1,74 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package helm
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/helm/pkg/chartutil"
+	"k8s.io/helm/pkg/proto/hapi/chart"
+)
+
+func TestLoadTransitiveDependencies(t *testing.T) {
+	dir, err := ioutil.TempDir("", "charts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// The umbrella chart vendors prometheus-operator unpacked, which vendors kube-state-metrics packaged.
+	files := map[string]string{
+		"umbrella/Chart.yaml":                                   "apiVersion: v1\nname: umbrella\nversion: 1.0.0\n",
+		"umbrella/requirements.yaml":                            "dependencies:\n  - name: prometheus-operator\n    repository: https://repo.evil.corp\n    version: 8.0.0\n",
+		"umbrella/charts/prometheus-operator/Chart.yaml":        "apiVersion: v1\nname: prometheus-operator\nversion: 8.0.0\n",
+		"umbrella/charts/prometheus-operator/requirements.yaml": "dependencies:\n  - name: kube-state-metrics\n    repository: https://repo.evil.corp\n    version: ~2.3.0\n  - name: local\n    repository: file://../local\n    version: 0.1.0\n",
+	}
+	for name, content := range files {
+		require.NoError(t, os.MkdirAll(path.Dir(path.Join(dir, name)), 0755))
+		require.NoError(t, ioutil.WriteFile(path.Join(dir, name), []byte(content), 0644))
+	}
+
+	// The packaged apiVersion v2 chart declares its dependencies in the Chart.yaml, which Helm 2 does not load. The Chart.lock is used instead.
+	kubeStateMetrics := &chart.Chart{
+		Metadata: &chart.Metadata{ApiVersion: apiVersionV2, Name: "kube-state-metrics", Version: "2.3.1"},
+		Files: []*any.Any{{
+			TypeUrl: chartLockName,
+			Value:   []byte("dependencies:\n- name: prometheus-node-exporter\n  repository: https://repo.evil.corp\n  version: 1.0.0\n"),
+		}},
+	}
+	require.NoError(t, os.MkdirAll(path.Join(dir, "umbrella/charts/prometheus-operator/charts"), 0755))
+	_, err = chartutil.Save(kubeStateMetrics, path.Join(dir, "umbrella/charts/prometheus-operator/charts"))
+	require.NoError(t, err, "there must be no error packaging the chart")
+
+	deps, err := loadTransitiveDependencies(path.Join(dir, "umbrella"), &Filter{})
+	require.NoError(t, err, "there should be no error loading the transitive dependencies")
+	require.Len(t, deps, 2, "local dependencies of subcharts should be skipped")
+
+	assert.Equal(t, []string{"umbrella", "prometheus-operator", "kube-state-metrics"}, deps[0].path)
+	assert.Equal(t, "2.3.1", deps[0].dep.Version, "the version of the vendored chart should be used")
+
+	assert.Equal(t, []string{"umbrella", "prometheus-operator", "kube-state-metrics", "prometheus-node-exporter"}, deps[1].path)
+	assert.Equal(t, "1.0.0", deps[1].dep.Version)
+	assert.Equal(t, "https://repo.evil.corp", deps[1].dep.Repository)
+}
@@ -21,6 +21,7 @@ package helm
 
 import (
 	"errors"
+	"path/filepath"
 	"strings"
 
 	"github.com/Masterminds/semver"
@@ -43,6 +44,20 @@ func normalizeRepoName(repoURL string) string {
 	return strings.ReplaceAll(name, ".", "-")
 }
 
+// relativePath returns the path relative to the given root path using forward slashes.
+// The path is returned as it is, if it cannot be made relative.
+func relativePath(rootPath, path string) string {
+	if path == "" {
+		return ""
+	}
+
+	relPath, err := filepath.Rel(rootPath, path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(relPath)
+}
+
 func normalizeString(theString string) string {
 	theString = strings.TrimSpace(theString)
 	return strings.ToLower(theString)
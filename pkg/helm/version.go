@@ -19,7 +19,21 @@
 
 package helm
 
-import "github.com/Masterminds/semver"
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/pkg/errors"
+)
+
+var (
+	// constraintOperatorRegex matches operators separated from their version by whitespace, like ">= 1.2".
+	constraintOperatorRegex = regexp.MustCompile(`([<>=~^!]+)\s+`)
+	// wildcardRegex matches wildcard segments of a version, like "1.2.x".
+	wildcardRegex = regexp.MustCompile(`(^|\.)[xX*]`)
+)
 
 // IncType is one of IncTypes.
 type IncType string
@@ -48,6 +62,20 @@ func (i IncType) IsGreater(inc IncType) bool {
 	return false
 }
 
+// IsAtLeast checks whether the IncType is equal to or greater than the given one.
+func (i IncType) IsAtLeast(inc IncType) bool {
+	return i != IncTypes.None && (i == inc || inc.IsGreater(i))
+}
+
+// ParseIncType parses the given update type, which is one of major, minor or patch.
+func ParseIncType(incType string) (IncType, error) {
+	switch i := IncType(strings.ToLower(incType)); i {
+	case IncTypes.Major, IncTypes.Minor, IncTypes.Patch:
+		return i, nil
+	}
+	return "", errors.Errorf("unknown update type %s, must be one of major, minor, patch", incType)
+}
+
 // GetIncType returns IncType based on which segment of the Version was changed.
 func GetIncType(oldVersion, newVersion *semver.Version) IncType {
 	if newVersion.Major() > oldVersion.Major() {
@@ -64,3 +92,132 @@ func GetIncType(oldVersion, newVersion *semver.Version) IncType {
 
 	return IncTypes.None
 }
+
+// incrementVersion increments the given segment of the version. The patch version is incremented by default.
+func incrementVersion(v *semver.Version, incType IncType) semver.Version {
+	switch incType {
+	case IncTypes.Major:
+		return v.IncMajor()
+	case IncTypes.Minor:
+		return v.IncMinor()
+	default:
+		return v.IncPatch()
+	}
+}
+
+// resolveVersion parses the given version of a dependency, which might also be a constraint like "~1.2.0".
+// For constraints the newest of the given versions satisfying it is returned along with the parsed constraint.
+func resolveVersion(version string, versions semver.Collection) (*semver.Version, *semver.Constraints, error) {
+	if v, err := semver.NewVersion(version); err == nil {
+		return v, nil, nil
+	}
+
+	constraint, err := semver.NewConstraint(version)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i := len(versions) - 1; i >= 0; i-- {
+		if constraint.Check(versions[i]) {
+			return versions[i], constraint, nil
+		}
+	}
+
+	return nil, constraint, errors.Errorf("no version satisfies the constraint %s", version)
+}
+
+// updateConstraint rewrites the given constraint, so it is satisfied by the given version.
+// The operators are kept, e.g. "~1.2.0" becomes "~1.4.3" and ">=1.0, <2.0" becomes ">=2.1.0, <3.0.0".
+func updateConstraint(constraint string, version *semver.Version) (string, error) {
+	if strings.Contains(constraint, "||") {
+		return "", errors.Errorf("cannot update constraint %s with alternatives", constraint)
+	}
+
+	var newConstraint string
+	if parts := strings.SplitN(constraint, " - ", 2); len(parts) == 2 {
+		// Keep the lower bound of hyphen ranges.
+		newConstraint = fmt.Sprintf("%s - %s", strings.TrimSpace(parts[0]), version.String())
+	} else {
+		var terms []string
+		constraint = constraintOperatorRegex.ReplaceAllString(constraint, "$1")
+		for _, term := range strings.FieldsFunc(constraint, func(r rune) bool { return r == ' ' || r == ',' }) {
+			t, err := updateConstraintTerm(term, version)
+			if err != nil {
+				return "", err
+			}
+			terms = append(terms, t)
+		}
+		// Terms are separated by commas as required by Helm.
+		newConstraint = strings.Join(terms, ", ")
+	}
+
+	c, err := semver.NewConstraint(newConstraint)
+	if err != nil {
+		return "", err
+	}
+
+	if !c.Check(version) {
+		return "", errors.Errorf("cannot update constraint %s to include version %s", constraint, version.String())
+	}
+
+	return newConstraint, nil
+}
+
+func updateConstraintTerm(term string, version *semver.Version) (string, error) {
+	versionStr := strings.TrimLeft(term, "<>=~^!")
+	operator := strings.TrimSuffix(term, versionStr)
+
+	switch operator {
+	case "!=":
+		return term, nil
+
+	case "<", "<=":
+		bound, err := semver.NewVersion(versionStr)
+		if err != nil {
+			return "", errors.Wrapf(err, "invalid upper bound %s", term)
+		}
+
+		if version.LessThan(bound) || (operator == "<=" && version.Equal(bound)) {
+			return term, nil
+		}
+
+		if operator == "<=" {
+			return operator + version.String(), nil
+		}
+
+		// Keep the granularity of the upper bound, e.g. "<2.0.0" becomes "<3.0.0".
+		newBound := version.IncPatch()
+		if bound.Patch() == 0 {
+			newBound = version.IncMinor()
+			if bound.Minor() == 0 {
+				newBound = version.IncMajor()
+			}
+		}
+		return operator + newBound.String(), nil
+
+	case ">":
+		// The version itself needs to satisfy the constraint.
+		operator = ">="
+	}
+
+	if wildcardRegex.MatchString(versionStr) {
+		return operator + updateWildcardVersion(versionStr, version), nil
+	}
+
+	return operator + version.String(), nil
+}
+
+// updateWildcardVersion replaces the segments of the given wildcard version with the ones of the version, e.g. "1.2.x" becomes "1.4.x".
+func updateWildcardVersion(wildcardVersion string, version *semver.Version) string {
+	segments := strings.Split(strings.TrimPrefix(wildcardVersion, "v"), ".")
+	versionSegments := []int64{version.Major(), version.Minor(), version.Patch()}
+	for idx, s := range segments {
+		if idx >= len(versionSegments) {
+			break
+		}
+		if s != "x" && s != "X" && s != "*" {
+			segments[idx] = fmt.Sprintf("%d", versionSegments[idx])
+		}
+	}
+	return strings.Join(segments, ".")
+}
@@ -0,0 +1,93 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package helm
+
+import (
+	"testing"
+
+	"github.com/Masterminds/semver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newVersions(versions ...string) semver.Collection {
+	var res semver.Collection
+	for _, v := range versions {
+		res = append(res, semver.MustParse(v))
+	}
+	return res
+}
+
+func TestResolveVersion(t *testing.T) {
+	versions := newVersions("1.2.0", "1.2.9", "1.4.3", "2.0.1")
+
+	tests := []struct {
+		version,
+		expectedVersion string
+		isConstraint bool
+	}{
+		{"1.2.0", "1.2.0", false},
+		{"~1.2.0", "1.2.9", true},
+		{"^1", "1.4.3", true},
+		{">=1.0, <2.0", "1.4.3", true},
+		{"1.2.x", "1.2.9", true},
+	}
+
+	for _, tt := range tests {
+		v, constraint, err := resolveVersion(tt.version, versions)
+		require.NoError(t, err, "there should be no error resolving version %s", tt.version)
+		assert.Equal(t, tt.expectedVersion, v.String(), "resolved version of %s", tt.version)
+		assert.Equal(t, tt.isConstraint, constraint != nil, "constraint of %s", tt.version)
+	}
+
+	_, _, err := resolveVersion("~3.0.0", versions)
+	assert.Error(t, err, "there should be an error if no version satisfies the constraint")
+}
+
+func TestUpdateConstraint(t *testing.T) {
+	latestVersion := semver.MustParse("2.1.0")
+
+	tests := map[string]string{
+		"~1.2.0":       "~2.1.0",
+		"^1":           "^2.1.0",
+		">=1.0, <2.0":  ">=2.1.0, <3.0.0",
+		">= 1.0, <1.5": ">=2.1.0, <2.2.0",
+		">1.0":         ">=2.1.0",
+		"1.2.x":        "2.1.x",
+		"1.x":          "2.x",
+		"1.0 - 1.9":    "1.0 - 2.1.0",
+	}
+
+	for constraint, expected := range tests {
+		newConstraint, err := updateConstraint(constraint, latestVersion)
+		require.NoError(t, err, "there should be no error updating constraint %s", constraint)
+		assert.Equal(t, expected, newConstraint, "updated constraint of %s", constraint)
+	}
+
+	_, err := updateConstraint("^1.0 || ^3.0", latestVersion)
+	assert.Error(t, err, "constraints with alternatives cannot be updated")
+}
+
+func TestIncTypeIsAtLeast(t *testing.T) {
+	assert.True(t, IncTypes.Major.IsAtLeast(IncTypes.Minor))
+	assert.True(t, IncTypes.Minor.IsAtLeast(IncTypes.Minor))
+	assert.False(t, IncTypes.Patch.IsAtLeast(IncTypes.Minor))
+	assert.False(t, IncTypes.None.IsAtLeast(IncTypes.Patch))
+}
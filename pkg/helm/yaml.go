@@ -0,0 +1,326 @@
+/*******************************************************************************
+*
+* Copyright 2019 SAP SE
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You should have received a copy of the License along with this
+* program. If not, you may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+*******************************************************************************/
+
+package helm
+
+import (
+	"bytes"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// dependencyKey identifies a dependency of a chart by its name and alias.
+type dependencyKey struct {
+	name,
+	alias string
+}
+
+// dependencyVersion is the version a dependency, identified by its name and alias, is set to.
+// The name and repository are replaced as well if newName and newRepository are set.
+// A dependency without alias, whose name is replaced, gets its old name as alias, so the values set for it still apply.
+type dependencyVersion struct {
+	name,
+	alias,
+	version string
+
+	newName,
+	newRepository string
+}
+
+// scalarEdit replaces the value of a scalar node.
+// Optionally raw text is inserted right after the value or as line after the one of the node.
+type scalarEdit struct {
+	node  *yamlv3.Node
+	value string
+	after,
+	nextLine string
+}
+
+// setDependencyVersions sets the versions and, if replaced, the names and repositories of the given dependencies in the YAML document.
+// Only these scalars are replaced. Comments, order and layout of the document are kept as they are.
+func setDependencyVersions(data []byte, depVersions []dependencyVersion) ([]byte, error) {
+	root, err := parseYamlDocument(data)
+	if err != nil {
+		return nil, err
+	}
+
+	depsNode := mappingValue(root, "dependencies")
+	if depsNode == nil || depsNode.Kind != yamlv3.SequenceNode {
+		return nil, errors.New("document has no list of dependencies")
+	}
+
+	var edits []scalarEdit
+	for _, dv := range depVersions {
+		found := false
+		for _, depNode := range depsNode.Content {
+			if scalarValue(mappingValue(depNode, "name")) != dv.name || scalarValue(mappingValue(depNode, "alias")) != dv.alias {
+				continue
+			}
+
+			versionNode := mappingValue(depNode, "version")
+			if versionNode == nil {
+				return nil, errors.Errorf("dependency %s has no version", dv.name)
+			}
+			edits = append(edits, scalarEdit{node: versionNode, value: dv.version})
+
+			if dv.newName != "" {
+				nameEdit := scalarEdit{node: mappingValue(depNode, "name"), value: dv.newName}
+				if dv.alias == "" && dv.newName != dv.name {
+					if err := addAliasEntry(&nameEdit, depNode, dv.name); err != nil {
+						return nil, err
+					}
+				}
+				edits = append(edits, nameEdit)
+			}
+			if dv.newRepository != "" {
+				repositoryNode := mappingValue(depNode, "repository")
+				if repositoryNode == nil {
+					return nil, errors.Errorf("dependency %s has no repository", dv.name)
+				}
+				edits = append(edits, scalarEdit{node: repositoryNode, value: dv.newRepository})
+			}
+			found = true
+		}
+
+		if !found {
+			return nil, errors.Errorf("dependency %s not found", dv.name)
+		}
+	}
+
+	return applyScalarEdits(data, edits)
+}
+
+// addAliasEntry adds the entry setting the alias of the dependency to the edit of its name.
+// In block style it is added as line after the name with the same indentation, in flow style right after the name.
+func addAliasEntry(nameEdit *scalarEdit, depNode *yamlv3.Node, alias string) error {
+	value, err := formatScalar(alias, 0)
+	if err != nil {
+		return err
+	}
+
+	if depNode.Style&yamlv3.FlowStyle != 0 {
+		nameEdit.after = ", alias: " + value
+		return nil
+	}
+
+	nameKey := mappingKey(depNode, "name")
+	if nameKey == nil {
+		return errors.New("dependency has no name")
+	}
+	nameEdit.nextLine = strings.Repeat(" ", nameKey.Column-1) + "alias: " + value
+	return nil
+}
+
+// findDependencyLines returns the line each dependency is declared in the given requirements.yaml or Chart.yaml.
+func findDependencyLines(path string) (map[dependencyKey]int, error) {
+	lines := make(map[dependencyKey]int)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return lines, err
+	}
+
+	root, err := parseYamlDocument(data)
+	if err != nil {
+		return lines, err
+	}
+
+	depsNode := mappingValue(root, "dependencies")
+	if depsNode == nil || depsNode.Kind != yamlv3.SequenceNode {
+		return lines, nil
+	}
+
+	for _, depNode := range depsNode.Content {
+		key := dependencyKey{name: scalarValue(mappingValue(depNode, "name")), alias: scalarValue(mappingValue(depNode, "alias"))}
+		lines[key] = depNode.Line
+	}
+	return lines, nil
+}
+
+// setChartVersion sets the version in the Chart.yaml keeping everything else as it is.
+func setChartVersion(data []byte, version string) ([]byte, error) {
+	root, err := parseYamlDocument(data)
+	if err != nil {
+		return nil, err
+	}
+
+	versionNode := mappingValue(root, "version")
+	if versionNode == nil {
+		return nil, errors.New("chart has no version")
+	}
+
+	return applyScalarEdits(data, []scalarEdit{{node: versionNode, value: version}})
+}
+
+func parseYamlDocument(data []byte) (*yamlv3.Node, error) {
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	if doc.Kind != yamlv3.DocumentNode || len(doc.Content) == 0 {
+		return nil, errors.New("empty document")
+	}
+	return doc.Content[0], nil
+}
+
+// mappingValue returns the value of the given key of a mapping node or nil.
+func mappingValue(node *yamlv3.Node, key string) *yamlv3.Node {
+	if node == nil || node.Kind != yamlv3.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// mappingKey returns the node of the given key of a mapping node or nil.
+func mappingKey(node *yamlv3.Node, key string) *yamlv3.Node {
+	if node == nil || node.Kind != yamlv3.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i]
+		}
+	}
+	return nil
+}
+
+func scalarValue(node *yamlv3.Node) string {
+	if node == nil || node.Kind != yamlv3.ScalarNode {
+		return ""
+	}
+	return node.Value
+}
+
+// applyScalarEdits replaces the scalars of the edits in the raw document using their position.
+// All other bytes of the document are left untouched.
+func applyScalarEdits(data []byte, edits []scalarEdit) ([]byte, error) {
+	lineOffsets := []int{0}
+	for idx, b := range data {
+		if b == '\n' {
+			lineOffsets = append(lineOffsets, idx+1)
+		}
+	}
+
+	type replacement struct {
+		start, end int
+		value      string
+	}
+
+	var replacements []replacement
+	for _, e := range edits {
+		if e.node.Kind != yamlv3.ScalarNode || e.node.Line < 1 || e.node.Line > len(lineOffsets) {
+			return nil, errors.Errorf("cannot replace value in line %d", e.node.Line)
+		}
+
+		if e.node.Value == e.value && e.after == "" && e.nextLine == "" {
+			continue
+		}
+
+		lineStart := lineOffsets[e.node.Line-1]
+		start := lineStart + columnOffset(data[lineStart:], e.node.Column)
+
+		// Plain values are in the document as they are.
+		oldToken := e.node.Value
+		if e.node.Style != 0 {
+			var err error
+			if oldToken, err = formatScalar(e.node.Value, e.node.Style); err != nil {
+				return nil, err
+			}
+		}
+
+		if !bytes.HasPrefix(data[start:], []byte(oldToken)) {
+			return nil, errors.Errorf("unexpected value in line %d, expected %s", e.node.Line, oldToken)
+		}
+
+		newToken, err := formatScalar(e.value, e.node.Style)
+		if err != nil {
+			return nil, err
+		}
+
+		replacements = append(replacements, replacement{start: start, end: start + len(oldToken), value: newToken + e.after})
+
+		if e.nextLine != "" {
+			lineEnd := len(data)
+			if e.node.Line < len(lineOffsets) {
+				lineEnd = lineOffsets[e.node.Line] - 1
+			}
+			replacements = append(replacements, replacement{start: lineEnd, end: lineEnd, value: "\n" + e.nextLine})
+		}
+	}
+
+	// Replace from the end of the document, so the offsets of the remaining replacements stay valid.
+	sort.Slice(replacements, func(i, j int) bool {
+		return replacements[i].start > replacements[j].start
+	})
+
+	res := append([]byte{}, data...)
+	for _, r := range replacements {
+		res = append(res[:r.start], append([]byte(r.value), res[r.end:]...)...)
+	}
+
+	// Ensure the result is still a valid document.
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal(res, &doc); err != nil {
+		return nil, errors.Wrap(err, "error replacing values")
+	}
+	return res, nil
+}
+
+// columnOffset returns the byte offset of the given 1-based column in the line.
+func columnOffset(line []byte, column int) int {
+	offset := 0
+	for i := 1; i < column && offset < len(line); i++ {
+		_, size := utf8.DecodeRune(line[offset:])
+		offset += size
+	}
+	return offset
+}
+
+// formatScalar formats the value as it appears in a document with the given style.
+// Plain values, which would be interpreted differently by a YAML parser, are double-quoted.
+func formatScalar(value string, style yamlv3.Style) (string, error) {
+	switch style {
+	case yamlv3.DoubleQuotedStyle:
+		return `"` + value + `"`, nil
+	case yamlv3.SingleQuotedStyle:
+		return `'` + value + `'`, nil
+	case 0:
+		out, err := yamlv3.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		if strings.TrimSuffix(string(out), "\n") != value {
+			return `"` + value + `"`, nil
+		}
+		return value, nil
+	}
+	return "", errors.New("only single-line scalars can be replaced")
+}